@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"github.com/flytam/filenamify"
 	goversion "github.com/hashicorp/go-version"
-	"github.com/pkoukk/tiktoken-go"
 	"github.com/samber/lo"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"io"
@@ -35,11 +34,23 @@ type App struct {
 	ctx      context.Context
 	logFile  *os.File
 	logToStd bool
+
+	sessionsMu sync.Mutex
+	sessions   map[int]*workspaceSession // by workspace id
+
+	gallery       *util.Gallery
+	musicLibrary  *util.MusicLibrary
+	artifactStore *util.ArtifactStore
+	uploadCache   *sydney.UploadCache
 }
 
 // NewApp creates a new App application struct
 func NewApp(settings *Settings) *App {
-	return &App{settings: settings}
+	return &App{settings: settings, sessions: map[int]*workspaceSession{},
+		gallery:       util.NewGallery(util.WithPath("gallery")),
+		musicLibrary:  util.NewMusicLibrary(util.WithPath("music")),
+		artifactStore: util.NewArtifactStore(util.WithPath("artifacts")),
+		uploadCache:   sydney.NewUploadCache(util.WithPath("upload_cache.json"), sydney.DefaultUploadCacheTTL)}
 }
 
 // startup is called when the app starts. The context is saved
@@ -59,6 +70,12 @@ func (a *App) startup(ctx context.Context) {
 		a.logToStd = false
 	}
 	a.updateLogger(a.settings.config.Debug)
+	if err := sydney.LoadPluginFile(util.WithPath("plugins.json")); err != nil {
+		slog.Error("Cannot load plugins", "err", err)
+	}
+	if err := sydney.LoadConversationStyleFile(util.WithPath("styles.json")); err != nil {
+		slog.Error("Cannot load conversation styles", "err", err)
+	}
 	go func() {
 		for debug := range a.settings.DebugChangeSignal {
 			a.updateLogger(debug)
@@ -80,19 +97,8 @@ func (a *App) updateLogger(debug bool) {
 	slog.Info("Update logger", "debug", debug)
 }
 
-var tk *tiktoken.Tiktoken
-var initTkFunc = sync.OnceFunc(func() {
-	slog.Info("Init tiktoken")
-	t, err := tiktoken.EncodingForModel("gpt-4")
-	if err != nil {
-		util.GracefulPanic(err)
-	}
-	tk = t
-})
-
 func (a *App) CountToken(text string) int {
-	initTkFunc()
-	return len(tk.Encode(text, nil, nil))
+	return util.CountToken(text)
 }
 
 type UploadSydneyImageResult struct {
@@ -101,12 +107,19 @@ type UploadSydneyImageResult struct {
 	Canceled  bool   `json:"canceled"`
 }
 
+// imageOptions returns how images are prepared before being uploaded, according to the settings.
+func (a *App) imageOptions() util.ImageOptions {
+	options := util.DefaultImageOptions
+	options.MaxDimension = a.settings.config.ImageMaxDimension
+	options.MaxBytes = a.settings.config.ImageMaxSize * 1024
+	return options
+}
 func (a *App) UploadSydneyImageFromBase64(rawBase64 string) (UploadSydneyImageResult, error) {
-	v, err := base64.StdEncoding.DecodeString(rawBase64)
+	v, err := util.DecodeBase64Image(rawBase64)
 	if err != nil {
 		return UploadSydneyImageResult{}, err
 	}
-	jpgData, err := util.ConvertImageToJpg(v)
+	jpgData, err := util.PrepareImage(v, a.imageOptions())
 	if err != nil {
 		return UploadSydneyImageResult{}, err
 	}
@@ -114,7 +127,7 @@ func (a *App) UploadSydneyImageFromBase64(rawBase64 string) (UploadSydneyImageRe
 	if err != nil {
 		return UploadSydneyImageResult{}, err
 	}
-	url, err := sydneyIns.UploadImage(jpgData)
+	url, err := sydneyIns.UploadImageContext(a.ctx, jpgData)
 	if err != nil {
 		return UploadSydneyImageResult{}, err
 	}
@@ -127,8 +140,8 @@ func (a *App) UploadSydneyImage() (UploadSydneyImageResult, error) {
 	file, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
 		Title: "Open an image to upload",
 		Filters: []runtime.FileFilter{{
-			DisplayName: "Image Files (*.jpg; *.jpeg; *.png; *.gif)",
-			Pattern:     "*.jpg;*.jpeg;*.png;*.gif",
+			DisplayName: "Image Files (*.jpg; *.jpeg; *.png; *.gif; *.webp; *.bmp; *.tif; *.tiff)",
+			Pattern:     "*.jpg;*.jpeg;*.png;*.gif;*.webp;*.bmp;*.tif;*.tiff",
 		}},
 	})
 	if err != nil {
@@ -145,11 +158,11 @@ func (a *App) UploadSydneyImage() (UploadSydneyImageResult, error) {
 	if err != nil {
 		return UploadSydneyImageResult{}, err
 	}
-	jpgData, err := util.ConvertImageToJpg(v)
+	jpgData, err := util.PrepareImage(v, a.imageOptions())
 	if err != nil {
 		return UploadSydneyImageResult{}, err
 	}
-	url, err := sydneyIns.UploadImage(jpgData)
+	url, err := sydneyIns.UploadImageContext(a.ctx, jpgData)
 	if err != nil {
 		return UploadSydneyImageResult{}, err
 	}
@@ -158,24 +171,29 @@ func (a *App) UploadSydneyImage() (UploadSydneyImageResult, error) {
 		BingURL:   url,
 	}, err
 }
-func (a *App) SelectUploadFile() (string, error) {
-	filePattern := strings.Join(lo.Map(sydney.BingAllowedFileExtensions, func(item string, index int) string {
+
+// ClearUploadCache forgets the earlier uploads, so that all images and files are uploaded again.
+func (a *App) ClearUploadCache() error {
+	return a.uploadCache.Clear()
+}
+func (a *App) SelectUploadFiles() ([]string, error) {
+	filePattern := strings.Join(lo.Map(sydney.UploadFileExtensions(), func(item string, index int) string {
 		return "*." + item
 	}), ";")
-	file, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
-		Title: "Open file to upload",
+	files, err := runtime.OpenMultipleFilesDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Open files to upload",
 		Filters: []runtime.FileFilter{{
 			DisplayName: "Custom Files (" + filePattern + ")",
 			Pattern:     filePattern,
 		}},
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return file, nil
+	return files, nil
 }
 func (a *App) SaveTempFileToUploadFromBase64(ext, rawBase64 string) (string, error) {
-	if !lo.Contains(sydney.BingAllowedFileExtensions, ext) {
+	if !lo.Contains(sydney.UploadFileExtensions(), ext) {
 		return "", errors.New("file extension " + ext + " is not allowed")
 	}
 	v, err := base64.StdEncoding.DecodeString(rawBase64)
@@ -264,12 +282,57 @@ func (a *App) FetchWebpage(url string) (string, error) {
 	return resp.String(), nil
 }
 
+// ImportCookies replaces cookies.json with the Bing cookies of text, in any format util.ParseCookieImport supports.
+// It returns the number of cookies imported.
+func (a *App) ImportCookies(text string) (int, error) {
+	cookies, err := util.ParseCookieImport(text)
+	if err != nil {
+		return 0, err
+	}
+	cookies = util.FilterCookiesByDomain(cookies, "bing.com")
+	if len(cookies) == 0 {
+		return 0, errors.New("no cookie of bing.com found")
+	}
+	if !lo.ContainsBy(cookies, func(cookie util.FileCookie) bool { return cookie.Name == "_U" }) {
+		slog.Warn("No _U cookie imported, the account may be not logged in")
+	}
+	return len(cookies), util.WriteCookiesFile(cookies)
+}
+func (a *App) ImportCookiesFromFile() (int, error) {
+	file, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Open cookies to import",
+		Filters: []runtime.FileFilter{{
+			DisplayName: "Cookies (*.json;*.txt)",
+			Pattern:     "*.json;*.txt",
+		}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if file == "" {
+		return 0, errors.New("no file selected")
+	}
+	v, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return a.ImportCookies(string(v))
+}
+
+// GetCookieExpiry returns the auth cookies that expire in 3 days or have expired.
+func (a *App) GetCookieExpiry() ([]util.CookieExpiry, error) {
+	cookies, err := util.ReadCookiesFileRaw()
+	if err != nil {
+		return nil, err
+	}
+	return util.ExpiringCookies(cookies, time.Now(), 3*24*time.Hour), nil
+}
 func (a *App) GetUser() (string, error) {
 	sydneyIns, err := a.createSydney()
 	if err != nil {
 		return "", err
 	}
-	return sydneyIns.GetUser()
+	return sydneyIns.GetUserContext(a.ctx)
 }
 
 type CheckUpdateResult struct {
@@ -321,13 +384,36 @@ func (a *App) CheckUpdate() (CheckUpdateResult, error) {
 	}, nil
 }
 
+// generativeMediaContext returns a context cancelled when the frontend emits EventGenerativeMediaStop
+// or the app shuts down.
+func (a *App) generativeMediaContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(a.ctx)
+	off := runtime.EventsOn(a.ctx, EventGenerativeMediaStop, func(optionalData ...interface{}) {
+		slog.Info("Received EventGenerativeMediaStop")
+		cancel()
+	})
+	return ctx, func() {
+		off()
+		cancel()
+	}
+}
 func (a *App) GenerateImage(generativeImage sydney.GenerativeImage) (sydney.GenerateImageResult, error) {
 	empty := sydney.GenerateImageResult{}
 	syd, err := a.createSydney()
 	if err != nil {
 		return empty, err
 	}
-	return syd.GenerateImage(generativeImage)
+	workspaceID := a.settings.config.CurrentWorkspaceID
+	ctx, cancel := a.generativeMediaContext()
+	defer cancel()
+	result, err := syd.GenerateImageContext(ctx, generativeImage)
+	if err != nil {
+		return empty, err
+	}
+	if a.settings.config.SaveGeneratedImages {
+		go a.saveToGallery(result, workspaceID)
+	}
+	return result, nil
 }
 func (a *App) GenerateMusic(generativeMusic sydney.GenerativeMusic) (sydney.GenerateMusicResult, error) {
 	var empty sydney.GenerateMusicResult
@@ -335,7 +421,21 @@ func (a *App) GenerateMusic(generativeMusic sydney.GenerativeMusic) (sydney.Gene
 	if err != nil {
 		return empty, err
 	}
-	return syd.GenerateMusic(generativeMusic)
+	workspaceID := a.settings.config.CurrentWorkspaceID
+	ctx, cancel := a.generativeMediaContext()
+	defer cancel()
+	result, err := syd.GenerateMusicContext(ctx, generativeMusic)
+	if err != nil {
+		return empty, err
+	}
+	if a.settings.config.SaveGeneratedMusic {
+		go func() {
+			if _, err := a.saveToMusicLibrary(result, workspaceID); err != nil {
+				slog.Warn("Cannot save generated music", "title", result.Title, "err", err)
+			}
+		}()
+	}
+	return result, nil
 }
 func (a *App) SaveRemoteJPEGImage(url string) error {
 	if strings.Contains(url, "?") {
@@ -435,8 +535,18 @@ func (a *App) ExportWorkspace(id int) error {
 	}
 	messages := util.GetChatMessage(workspace.Context)
 	var out bytes.Buffer
+	var sources []sydney.SourceAttribute // of the last search result, for citations of the next message
 	for _, msg := range messages {
-		out.WriteString(fmt.Sprintf("# \\[%s\\](#%s)\n%s\n\n", msg.Role, msg.Type, msg.Content))
+		content := msg.Content
+		if msg.Role == "assistant" && msg.Type == sydney.MessageTypeSearchResult {
+			if err := json.Unmarshal([]byte(msg.Content), &sources); err != nil {
+				slog.Warn("Cannot parse search result for citations", "err", err)
+			}
+		} else if msg.Role == "assistant" && msg.Type == sydney.MessageTypeMessageText {
+			content = sydney.RewriteCitations(content, sources)
+			sources = nil
+		}
+		out.WriteString(fmt.Sprintf("# \\[%s\\](#%s)\n%s\n\n", msg.Role, msg.Type, content))
 	}
 	input := strings.TrimSpace(workspace.Input)
 	if input != "" {
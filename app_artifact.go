@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sydneyqt/sydney"
+	"sydneyqt/util"
+
+	"github.com/flytam/filenamify"
+	"github.com/samber/lo"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// codeArtifacts collects the code and the outputs of the code interpreter in a turn, one artifact
+// per piece of generated code. Outputs before any code make an artifact of their own.
+type codeArtifacts struct {
+	prompt      string
+	workspaceID int
+	artifacts   []util.Artifact
+}
+
+func (o *codeArtifacts) add(msg sydney.Message) {
+	switch msg.Type {
+	case sydney.MessageTypeGeneratedCode:
+		o.artifacts = append(o.artifacts, util.Artifact{
+			WorkspaceID: o.workspaceID,
+			Prompt:      o.prompt,
+			Code:        msg.GeneratedCode.Code,
+			Language:    msg.GeneratedCode.Language,
+		})
+	case sydney.MessageTypeCodeOutput:
+		if len(o.artifacts) == 0 {
+			o.artifacts = append(o.artifacts, util.Artifact{WorkspaceID: o.workspaceID, Prompt: o.prompt})
+		}
+		last := &o.artifacts[len(o.artifacts)-1]
+		for _, file := range msg.CodeOutput {
+			last.Files = append(last.Files, util.ArtifactFile{Name: file.Name, URL: file.URL, Kind: file.Kind})
+		}
+	}
+}
+
+// codeMarkdown renders the generated code as a fenced code block for the chat context.
+func codeMarkdown(code sydney.GeneratedCode) string {
+	return "```" + code.Language + "\n" + code.Code + "\n```\n\n"
+}
+
+// codeOutputMarkdown renders the outputs of the code interpreter as images and links for the chat context.
+func codeOutputMarkdown(files []sydney.CodeOutputFile) string {
+	return strings.Join(lo.Map(files, func(file sydney.CodeOutputFile, _ int) string {
+		return lo.Ternary(file.Kind == sydney.CodeOutputKindImage, "!", "") + "[" + file.Name + "](" + file.URL + ")"
+	}), "\n\n") + "\n\n"
+}
+
+// saveArtifacts downloads the outputs of the artifacts and adds them to the artifact store. Failures are
+// only logged.
+func (a *App) saveArtifacts(artifacts []util.Artifact) {
+	for _, artifact := range artifacts {
+		if _, err := a.artifactStore.Save(a.ctx, a.settings.config.Proxy, artifact); err != nil {
+			slog.Warn("Cannot save code interpreter artifact", "prompt", artifact.Prompt, "err", err)
+		}
+	}
+}
+
+// ListArtifacts returns the code interpreter artifacts of the workspace, or of all workspaces if
+// workspaceID is 0, the newest first.
+func (a *App) ListArtifacts(workspaceID int) ([]util.Artifact, error) {
+	return a.artifactStore.List(workspaceID)
+}
+func (a *App) DeleteArtifact(id string) error {
+	return a.artifactStore.Delete(id)
+}
+
+// SaveArtifact copies the code and the files of the artifact into a directory chosen by the user.
+func (a *App) SaveArtifact(id string) error {
+	artifact, err := a.artifactStore.Get(id)
+	if err != nil {
+		return err
+	}
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "Choose a directory to save the artifact",
+		CanCreateDirectories: true,
+	})
+	if err != nil {
+		return err
+	}
+	if dir == "" { // cancelled
+		return nil
+	}
+	return a.artifactStore.Export(artifact, dir)
+}
+
+// SaveArtifactsAsZip saves all the artifacts of the workspace, or of all workspaces if workspaceID is 0,
+// as a zip archive chosen by the user.
+func (a *App) SaveArtifactsAsZip(workspaceID int) error {
+	artifacts, err := a.artifactStore.List(workspaceID)
+	if err != nil {
+		return err
+	}
+	if len(artifacts) == 0 {
+		return errors.New("there are no artifacts to save")
+	}
+	title := "artifacts"
+	if workspace, ok := lo.Find(a.settings.config.Workspaces, func(item Workspace) bool {
+		return item.ID == workspaceID
+	}); ok {
+		title = workspace.Title + " artifacts"
+	}
+	fn, err := filenamify.FilenamifyV2(title + ".zip")
+	if err != nil {
+		return err
+	}
+	filePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title: "Choose a destination to save the artifacts",
+		Filters: []runtime.FileFilter{{
+			DisplayName: "Zip Archives (*.zip)",
+			Pattern:     "*.zip",
+		}},
+		DefaultFilename:      fn,
+		CanCreateDirectories: true,
+	})
+	if err != nil {
+		return err
+	}
+	if filePath == "" { // cancelled
+		return nil
+	}
+	if !strings.HasSuffix(filePath, ".zip") {
+		filePath += ".zip"
+	}
+	var buf bytes.Buffer
+	if err := a.artifactStore.WriteZip(&buf, artifacts); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, buf.Bytes(), 0644)
+}
+
+// OpenArtifactWorkspace makes the workspace the artifact was produced in the current one.
+func (a *App) OpenArtifactWorkspace(id string) error {
+	artifact, err := a.artifactStore.Get(id)
+	if err != nil {
+		return err
+	}
+	return a.switchWorkspace(artifact.WorkspaceID)
+}
@@ -3,8 +3,8 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/life4/genesis/slices"
 	"github.com/samber/lo"
 	"github.com/sashabaranov/go-openai"
@@ -26,21 +26,53 @@ const (
 type AskType int
 
 type AskOptions struct {
-	Type           AskType `json:"type"`
-	OpenAIBackend  string  `json:"openai_backend"`
-	ChatContext    string  `json:"chat_context"`
-	Prompt         string  `json:"prompt"`
-	ImageURL       string  `json:"image_url"`
-	UploadFilePath string  `json:"upload_file_path"`
-	Model          string  `json:"model"` // for openai models
+	Type            AskType  `json:"type"`
+	OpenAIBackend   string   `json:"openai_backend"`
+	ChatContext     string   `json:"chat_context"`
+	Prompt          string   `json:"prompt"`
+	ImageURL        string   `json:"image_url"`
+	UploadFilePaths []string `json:"upload_file_paths"`
+	Model           string   `json:"model"` // for openai models
 }
 
 const (
-	ChatFinishResultErrTypeMessageRevoke   = "message_revoke"
-	ChatFinishResultErrTypeMessageFiltered = "message_filtered"
-	ChatFinishResultErrTypeOthers          = "others"
+	ChatFinishResultErrTypeMessageRevoke     = "message_revoke"
+	ChatFinishResultErrTypeMessageFiltered   = "message_filtered"
+	ChatFinishResultErrTypeCookieExpired     = "cookie_expired"
+	ChatFinishResultErrTypeRegionUnsupported = "region_unsupported"
+	ChatFinishResultErrTypeThrottled         = "throttled"
+	ChatFinishResultErrTypeCaptchaRequired   = "captcha_required"
+	ChatFinishResultErrTypeNetwork           = "network"
+	ChatFinishResultErrTypeContextTooLong    = "context_too_long"
+	ChatFinishResultErrTypeStreamStalled     = "stream_stalled"
+	ChatFinishResultErrTypeFileTooLarge      = "file_too_large"
+	ChatFinishResultErrTypeOthers            = "others"
 )
 
+// chatFinishErrType maps the errors of package sydney to ChatFinishResult.ErrType.
+func chatFinishErrType(err error) string {
+	for _, item := range []struct {
+		err     error
+		errType string
+	}{
+		{sydney.ErrMessageRevoke, ChatFinishResultErrTypeMessageRevoke},
+		{sydney.ErrMessageFiltered, ChatFinishResultErrTypeMessageFiltered},
+		{sydney.ErrCookieExpired, ChatFinishResultErrTypeCookieExpired},
+		{sydney.ErrRegionUnsupported, ChatFinishResultErrTypeRegionUnsupported},
+		{sydney.ErrThrottled, ChatFinishResultErrTypeThrottled},
+		{sydney.ErrCaptchaRequired, ChatFinishResultErrTypeCaptchaRequired},
+		{sydney.ErrNetwork, ChatFinishResultErrTypeNetwork},
+		{sydney.ErrContextTooLong, ChatFinishResultErrTypeContextTooLong},
+		{sydney.ErrStreamStalled, ChatFinishResultErrTypeStreamStalled},
+		{sydney.ErrFileTooLarge, ChatFinishResultErrTypeFileTooLarge},
+	} {
+		if errors.Is(err, item.err) {
+			return item.errType
+		}
+	}
+	return ChatFinishResultErrTypeOthers
+}
+
 type ChatFinishResult struct {
 	Success bool   `json:"success"`
 	ErrType string `json:"err_type"`
@@ -56,40 +88,107 @@ const (
 	EventChatGenerateImage      = "chat_generate_image"
 	EventChatGenerateMusic      = "chat_generate_music"
 	EventChatResolvingCaptcha   = "chat_resolving_captcha"
+	EventChatContextTrimmed     = "chat_context_trimmed"
+	EventChatUploadProgress     = "chat_upload_progress"
 )
 
 const (
-	EventChatStop = "chat_stop"
+	EventChatStop            = "chat_stop"
+	EventGenerativeMediaStop = "generative_media_stop"
 )
 
 func (a *App) Dummy1() ChatFinishResult {
 	return ChatFinishResult{}
 }
+
+// saveUpdatedCookies persists the cookies updated by Bing or the CAPTCHA solver to cookies.json.
+func saveUpdatedCookies(cookies map[string]string) {
+	if err := util.UpdateCookiesFile(cookies); err != nil {
+		slog.Warn("Cannot update cookies file", "err", err)
+	}
+}
 func (a *App) createSydney() (*sydney.Sydney, error) {
 	currentWorkspace, err := a.settings.config.GetCurrentWorkspace()
 	if err != nil {
 		return nil, err
 	}
-	cookies, err := util.ReadCookiesFile()
+	rawCookies, err := util.ReadCookiesFileRaw()
 	if err != nil {
 		return nil, err
 	}
+	// fail early instead of at conversation creation
+	if expired := util.ExpiringCookies(rawCookies, time.Now(), 0); len(expired) != 0 {
+		return nil, fmt.Errorf("%w: cookie %s expired at %s, please import new cookies",
+			sydney.ErrCookieExpired, expired[0].Name, expired[0].ExpiresAt.Format(time.DateTime))
+	}
+	cookies := lo.SliceToMap(rawCookies, func(cookie util.FileCookie) (string, string) {
+		return cookie.Name, cookie.Value
+	})
 	return sydney.NewSydney(sydney.Options{
 		Debug:                 a.settings.config.Debug,
 		Cookies:               cookies,
 		Proxy:                 a.settings.config.Proxy,
 		ConversationStyle:     currentWorkspace.ConversationStyle,
 		Locale:                currentWorkspace.Locale,
+		Location:              currentWorkspace.Location,
 		WssDomain:             a.settings.config.WssDomain,
 		CreateConversationURL: a.settings.config.CreateConversationURL,
 		NoSearch:              currentWorkspace.NoSearch,
 		UseClassic:            currentWorkspace.UseClassic,
 		GPT4Turbo:             currentWorkspace.GPT4Turbo,
 		BypassServer:          a.settings.config.BypassServer,
+		CaptchaSolverName:     a.settings.config.CaptchaSolver,
+		OnCookiesUpdated:      saveUpdatedCookies,
 		Plugins:               currentWorkspace.Plugins,
+		TranscriptDir:         lo.Ternary(a.settings.config.DebugTranscript, util.WithPath("transcripts"), ""),
+		UploadMaxSize:         a.settings.config.UploadMaxSize << 20,
+		KeepaliveInterval:     time.Duration(a.settings.config.KeepaliveInterval) * time.Second,
+		ReadTimeout:           time.Duration(a.settings.config.ReadTimeout) * time.Second,
+		IdleTimeout:           time.Duration(a.settings.config.IdleTimeout) * time.Second,
+		PollInterval:          time.Duration(a.settings.config.PollInterval) * time.Second,
+		PollAttempts:          a.settings.config.PollAttempts,
+		UploadCache:           lo.Ternary(a.settings.config.DisableUploadCache, nil, a.uploadCache),
 	}), nil
 }
 
+// GetPlugins returns the plugins to choose from for a workspace, including those of plugins.json.
+func (a *App) GetPlugins() []sydney.Plugin {
+	return sydney.Plugins()
+}
+
+// GetConversationStyles returns the conversation styles to choose from, including those of styles.json.
+func (a *App) GetConversationStyles() []sydney.ConversationStyle {
+	return sydney.ConversationStyles()
+}
+
+// GetLocations returns the built-in locations to choose from for a workspace.
+func (a *App) GetLocations() []sydney.Location {
+	return sydney.LocationList
+}
+
+type workspaceSession struct {
+	session *sydney.Session
+	key     string // options the session was created with
+	context string // chat context sent with the last turn
+}
+
+// getSydneySession returns the session of the workspace, or a new one if the options
+// have changed or the chat context is no longer a continuation of the last turn. Turns are asked by
+// the Sydney created with the current settings, so changed cookies or proxy apply to the next turn.
+func (a *App) getSydneySession(workspace Workspace, chatContext string) *sydney.Session {
+	a.sessionsMu.Lock()
+	defer a.sessionsMu.Unlock()
+	key := fmt.Sprint(workspace.ConversationStyle, workspace.Locale, workspace.Location, workspace.NoSearch,
+		workspace.UseClassic, workspace.GPT4Turbo, workspace.Plugins)
+	ws, ok := a.sessions[workspace.ID]
+	if !ok || ws.key != key || !strings.HasPrefix(chatContext, ws.context) {
+		slog.Info("Create new sydney session", "workspace", workspace.ID)
+		ws = &workspaceSession{session: sydney.NewSession(), key: key}
+		a.sessions[workspace.ID] = ws
+	}
+	ws.context = chatContext
+	return ws.session
+}
 func (a *App) askSydney(options AskOptions) {
 	slog.Info("askSydney called", "options", options)
 	chatFinishResult := ChatFinishResult{
@@ -105,7 +204,7 @@ func (a *App) askSydney(options AskOptions) {
 	if err != nil {
 		chatFinishResult = ChatFinishResult{
 			Success: false,
-			ErrType: ChatFinishResultErrTypeOthers,
+			ErrType: chatFinishErrType(err),
 			ErrMsg:  err.Error(),
 		}
 		return
@@ -119,18 +218,30 @@ func (a *App) askSydney(options AskOptions) {
 		runtime.EventsOff(a.ctx, EventChatStop)
 	})
 
+	var session *sydney.Session
+	if currentWorkspace, err := a.settings.config.GetCurrentWorkspace(); err == nil &&
+		currentWorkspace.ReuseConversation {
+		session = a.getSydneySession(currentWorkspace, options.ChatContext)
+	}
 	ch, err := sydneyIns.AskStream(sydney.AskStreamOptions{
-		StopCtx:        stopCtx,
-		Prompt:         options.Prompt,
-		WebpageContext: options.ChatContext,
-		ImageURL:       options.ImageURL,
-		UploadFilePath: options.UploadFilePath,
+		StopCtx:          stopCtx,
+		Session:          session,
+		Prompt:           options.Prompt,
+		WebpageContext:   options.ChatContext,
+		ImageURL:         options.ImageURL,
+		UploadFilePaths:  options.UploadFilePaths,
+		OnUploadProgress: a.emitUploadProgress,
+		RevokeReplyText:  a.settings.config.RevokeReplyText,
+		RevokeReplyCount: a.settings.config.RevokeReplyCount,
+		TokenBudget:      a.settings.config.ContextTokenBudget,
+		TrimStrategy:     a.settings.config.ContextTrimStrategy,
+		Summarize:        sydney.SummarizeWith(sydneyIns),
 	})
 	if err != nil {
 		if !errors.Is(err, context.Canceled) {
 			chatFinishResult = ChatFinishResult{
 				Success: false,
-				ErrType: ChatFinishResultErrTypeOthers,
+				ErrType: chatFinishErrType(err),
 				ErrMsg:  err.Error(),
 			}
 		}
@@ -141,6 +252,12 @@ func (a *App) askSydney(options AskOptions) {
 	chatAppend := func(text string) {
 		runtime.EventsEmit(a.ctx, EventChatAppend, text)
 	}
+	artifacts := codeArtifacts{prompt: options.Prompt, workspaceID: a.settings.config.CurrentWorkspaceID}
+	defer func() {
+		if len(artifacts.artifacts) != 0 {
+			go a.saveArtifacts(artifacts.artifacts)
+		}
+	}()
 	fullMessageText := ""
 	lastMessageType := ""
 	receivedBingSearchDisabledLoader := false
@@ -148,26 +265,12 @@ func (a *App) askSydney(options AskOptions) {
 		textToAppend := ""
 		switch msg.Type {
 		case sydney.MessageTypeSuggestedResponses:
-			runtime.EventsEmit(a.ctx, EventChatSuggestedResponses, msg.Text)
+			runtime.EventsEmit(a.ctx, EventChatSuggestedResponses, msg.SuggestedResponses)
 		case sydney.MessageTypeError:
-			if errors.Is(msg.Error, sydney.ErrMessageRevoke) {
-				chatFinishResult = ChatFinishResult{
-					Success: false,
-					ErrType: ChatFinishResultErrTypeMessageRevoke,
-					ErrMsg:  msg.Error.Error(),
-				}
-			} else if errors.Is(msg.Error, sydney.ErrMessageFiltered) {
-				chatFinishResult = ChatFinishResult{
-					Success: false,
-					ErrType: ChatFinishResultErrTypeMessageFiltered,
-					ErrMsg:  msg.Error.Error(),
-				}
-			} else {
-				chatFinishResult = ChatFinishResult{
-					Success: false,
-					ErrType: ChatFinishResultErrTypeOthers,
-					ErrMsg:  msg.Error.Error(),
-				}
+			chatFinishResult = ChatFinishResult{
+				Success: false,
+				ErrType: chatFinishErrType(msg.Error),
+				ErrMsg:  msg.Error.Error(),
 			}
 			return
 		case sydney.MessageTypeMessageText:
@@ -175,15 +278,11 @@ func (a *App) askSydney(options AskOptions) {
 			runtime.EventsEmit(a.ctx, EventChatToken, a.CountToken(fullMessageText))
 			textToAppend = msg.Text
 		case sydney.MessageTypeGenerativeImage:
-			var image sydney.GenerativeImage
-			lo.Must0(json.Unmarshal([]byte(msg.Text), &image))
-			runtime.EventsEmit(a.ctx, EventChatGenerateImage, image)
-			textToAppend = image.Text + "\n\n"
+			runtime.EventsEmit(a.ctx, EventChatGenerateImage, msg.GenerativeImage)
+			textToAppend = msg.GenerativeImage.Text + "\n\n"
 		case sydney.MessageTypeGenerativeMusic:
-			var music sydney.GenerativeMusic
-			lo.Must0(json.Unmarshal([]byte(msg.Text), &music))
-			runtime.EventsEmit(a.ctx, EventChatGenerateMusic, music)
-			textToAppend = music.Text + "\n\n"
+			runtime.EventsEmit(a.ctx, EventChatGenerateMusic, msg.GenerativeMusic)
+			textToAppend = msg.GenerativeMusic.Text + "\n\n"
 		case sydney.MessageTypeLoading:
 			if a.settings.config.DisableNoSearchLoader {
 				if msg.Text == "BingSearchDisabled" {
@@ -199,6 +298,14 @@ func (a *App) askSydney(options AskOptions) {
 			textToAppend = msg.Text + "\n\n"
 		case sydney.MessageTypeResolvingCaptcha:
 			runtime.EventsEmit(a.ctx, EventChatResolvingCaptcha, msg.Text)
+		case sydney.MessageTypeContextTrimmed:
+			runtime.EventsEmit(a.ctx, EventChatContextTrimmed, msg.ContextTrim)
+		case sydney.MessageTypeGeneratedCode:
+			artifacts.add(msg)
+			textToAppend = codeMarkdown(*msg.GeneratedCode)
+		case sydney.MessageTypeCodeOutput:
+			artifacts.add(msg)
+			textToAppend = codeOutputMarkdown(msg.CodeOutput)
 		default:
 			textToAppend = msg.Text + "\n\n"
 		}
@@ -211,6 +318,9 @@ func (a *App) askSydney(options AskOptions) {
 		lastMessageType = msg.Type
 	}
 }
+func (a *App) emitUploadProgress(progress sydney.UploadProgress) {
+	runtime.EventsEmit(a.ctx, EventChatUploadProgress, progress)
+}
 func (a *App) askOpenAI(options AskOptions) {
 	chatFinishResult := ChatFinishResult{
 		Success: true,
@@ -373,6 +483,7 @@ func (a *App) GetConciseAnswer(req ConciseAnswerReq) (string, error) {
 			CreateConversationURL: a.settings.config.CreateConversationURL,
 			NoSearch:              true,
 			UseClassic:            false,
+			OnCookiesUpdated:      saveUpdatedCookies,
 		})
 		ch, err := syd.AskStream(sydney.AskStreamOptions{
 			StopCtx:        context.Background(),
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sydneyqt/sydney"
+	"sydneyqt/util"
+
+	"github.com/samber/lo"
+)
+
+// assetHandler serves the images of the gallery at /gallery/<file_name>, the assets of the music library
+// at /music/<id>/<file> and the files of the artifacts at /artifacts/<id>/<file> to the frontend.
+func (a *App) assetHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/gallery/", http.StripPrefix("/gallery/", http.FileServer(http.Dir(a.gallery.Dir()))))
+	mux.Handle("/music/", http.StripPrefix("/music/", http.FileServer(http.Dir(a.musicLibrary.Dir()))))
+	mux.Handle("/artifacts/", http.StripPrefix("/artifacts/", http.FileServer(http.Dir(a.artifactStore.Dir()))))
+	return mux
+}
+
+// saveToGallery downloads the generated images into the gallery. Failures are only logged.
+func (a *App) saveToGallery(result sydney.GenerateImageResult, workspaceID int) {
+	for _, url := range result.ImageURLs {
+		url = strings.Split(url, "?")[0] // without the thumbnail parameters
+		data, err := util.Download(a.ctx, a.settings.config.Proxy, url)
+		if err != nil {
+			slog.Warn("Cannot download generated image", "url", url, "err", err)
+			continue
+		}
+		_, err = a.gallery.Add(util.GalleryImage{
+			Prompt:      result.GenerativeImage.Text,
+			WorkspaceID: workspaceID,
+			SourceURL:   url,
+			Duration:    result.Duration,
+		}, data)
+		if err != nil {
+			slog.Warn("Cannot save generated image", "url", url, "err", err)
+		}
+	}
+}
+
+// ListGalleryImages returns the saved images, the newest first.
+func (a *App) ListGalleryImages() ([]util.GalleryImage, error) {
+	return a.gallery.List()
+}
+
+// SearchGalleryImages returns the saved images whose prompts contain every word of query.
+func (a *App) SearchGalleryImages(query string) ([]util.GalleryImage, error) {
+	return a.gallery.Search(query)
+}
+func (a *App) DeleteGalleryImage(id string) error {
+	return a.gallery.Delete(id)
+}
+
+// OpenGalleryImageWorkspace makes the workspace the image was generated in the current one.
+func (a *App) OpenGalleryImageWorkspace(id string) error {
+	image, err := a.gallery.Get(id)
+	if err != nil {
+		return err
+	}
+	return a.switchWorkspace(image.WorkspaceID)
+}
+
+// switchWorkspace makes the workspace the current one, for the frontend to open when the index page mounts.
+func (a *App) switchWorkspace(workspaceID int) error {
+	config := a.settings.GetConfig()
+	if !lo.ContainsBy(config.Workspaces, func(workspace Workspace) bool {
+		return workspace.ID == workspaceID
+	}) {
+		return errors.New("the workspace has been deleted")
+	}
+	config.CurrentWorkspaceID = workspaceID
+	a.settings.SetConfig(config)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sydneyqt/sydney"
+	"sydneyqt/util"
+)
+
+func (a *App) saveToMusicLibrary(result sydney.GenerateMusicResult, workspaceID int) (util.MusicTrack, error) {
+	return a.musicLibrary.Save(a.ctx, a.settings.config.Proxy, util.MusicTrack{
+		Title:         result.Title,
+		MusicalStyle:  result.MusicalStyle,
+		Lyrics:        result.Lyrics,
+		Prompt:        result.GenerativeMusic.Text,
+		WorkspaceID:   workspaceID,
+		MusicDuration: result.MusicDuration,
+		AudioURL:      result.AudioURL,
+		VideoURL:      result.VideoURL,
+		CoverImgURL:   result.CoverImgURL,
+	})
+}
+
+// AddMusicToLibrary downloads the generated music into the music library for the current workspace.
+func (a *App) AddMusicToLibrary(result sydney.GenerateMusicResult) (util.MusicTrack, error) {
+	return a.saveToMusicLibrary(result, a.settings.config.CurrentWorkspaceID)
+}
+
+// SearchMusicTracks returns the tracks of the music library matching the query, the newest first.
+func (a *App) SearchMusicTracks(query util.MusicQuery) ([]util.MusicTrack, error) {
+	return a.musicLibrary.Search(query)
+}
+
+// GetMusicStyles returns the musical styles of the tracks in the music library.
+func (a *App) GetMusicStyles() ([]string, error) {
+	return a.musicLibrary.Styles()
+}
+func (a *App) DeleteMusicTrack(id string) error {
+	return a.musicLibrary.Delete(id)
+}
+
+// OpenMusicTrackWorkspace makes the workspace the track was generated in the current one.
+func (a *App) OpenMusicTrackWorkspace(id string) error {
+	track, err := a.musicLibrary.Get(id)
+	if err != nil {
+		return err
+	}
+	return a.switchWorkspace(track.WorkspaceID)
+}
@@ -0,0 +1,300 @@
+// Command sydney chats with Bing from the terminal, either answering a single prompt given as arguments
+// or read from stdin, or in an interactive session.
+//
+//	sydney [flags] [prompt...]
+//
+// The message text is written to stdout and the other messages, like search queries, sources and images,
+// to stderr as compact notes. If both a prompt and piped stdin are given, stdin is sent as the context.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sydneyqt/sydney"
+	"sydneyqt/util"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+type stringsFlag []string
+
+func (o *stringsFlag) String() string {
+	return strings.Join(*o, ",")
+}
+func (o *stringsFlag) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+type cliOptions struct {
+	cookies      string
+	proxy        string
+	bypassServer string
+	style        string
+	locale       string
+	noSearch     bool
+	plugins      string
+	image        string
+	files        stringsFlag
+	interactive  bool
+	quiet        bool
+	debug        bool
+}
+
+func main() {
+	var options cliOptions
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError) // not flag.CommandLine, where rod adds its own
+	flags.StringVar(&options.cookies, "cookies", "",
+		"cookie string like \"_U=xxx; SRCHHPGUSR=xxx\", or a cookie file in JSON or Netscape format "+
+			"(default: cookies.json)")
+	proxy := os.Getenv("HTTPS_PROXY")
+	if proxy == "" {
+		proxy = os.Getenv("HTTP_PROXY")
+	}
+	flags.StringVar(&options.proxy, "proxy", proxy, "proxy for the requests to Microsoft")
+	flags.StringVar(&options.bypassServer, "bypass-server", "",
+		"full URL of the CAPTCHA-bypass server; without it, a CAPTCHA fails the answer")
+	flags.StringVar(&options.style, "style", sydney.DefaultConversationStyle, "conversation style, one of "+
+		strings.Join(lo.Map(sydney.ConversationStyles(), func(style sydney.ConversationStyle, _ int) string {
+			return style.Name
+		}), ", "))
+	flags.StringVar(&options.locale, "locale", "en-US", "locale of the answers")
+	flags.BoolVar(&options.noSearch, "no-search", false, "disable web search")
+	flags.StringVar(&options.plugins, "plugins", "", "comma-separated names of the plugins to enable")
+	flags.StringVar(&options.image, "image", "", "image file to ask about")
+	flags.Var(&options.files, "file", "file to attach; may be repeated")
+	flags.BoolVar(&options.interactive, "i", false,
+		"chat interactively, which is the default if there is no prompt and stdin is a terminal")
+	flags.BoolVar(&options.quiet, "q", false, "write only the message text, without the notes of other messages")
+	flags.BoolVar(&options.debug, "debug", false, "write debug logs to stderr")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: %s [flags] [prompt...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: lo.Ternary(options.debug, slog.LevelDebug, slog.LevelError),
+	})))
+	if err := run(options, flags.Args()); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+func run(options cliOptions, args []string) error {
+	if err := sydney.LoadPluginFile(util.WithPath("plugins.json")); err != nil {
+		return err
+	}
+	if err := sydney.LoadConversationStyleFile(util.WithPath("styles.json")); err != nil {
+		return err
+	}
+	syd, err := newSydney(options)
+	if err != nil {
+		return err
+	}
+	imageURL := ""
+	if options.image != "" {
+		imageURL, err = uploadImage(syd, options.image)
+		if err != nil {
+			return err
+		}
+	}
+	ask := askOptions{imageURL: imageURL, files: options.files}
+	stdinIsTerminal := isTerminal(os.Stdin)
+	prompt := strings.Join(args, " ")
+	if options.interactive || (prompt == "" && stdinIsTerminal) {
+		return repl(syd, options, ask, prompt)
+	}
+	if stdinIsTerminal {
+		ask.prompt = prompt
+	} else {
+		v, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if prompt == "" {
+			ask.prompt = string(v)
+		} else {
+			ask.prompt, ask.context = prompt, string(v)
+		}
+	}
+	if strings.TrimSpace(ask.prompt) == "" {
+		return errors.New("the prompt is empty")
+	}
+	return askOnce(syd, nil, options, ask)
+}
+
+// newSydney creates a Sydney from the options, failing on unknown conversation styles and plugins
+// instead of falling back like sydney.NewSydney.
+func newSydney(options cliOptions) (*sydney.Sydney, error) {
+	cookies, err := readCookies(options.cookies)
+	if err != nil {
+		return nil, err
+	}
+	style, ok := lo.Find(sydney.ConversationStyles(), func(style sydney.ConversationStyle) bool {
+		return strings.EqualFold(style.Name, options.style)
+	})
+	if !ok {
+		return nil, errors.New("unknown conversation style: " + options.style)
+	}
+	plugins := lo.Compact(lo.Map(strings.Split(options.plugins, ","), func(name string, _ int) string {
+		return strings.TrimSpace(name)
+	}))
+	for i, name := range plugins {
+		plugin, ok := lo.Find(sydney.Plugins(), func(plugin sydney.Plugin) bool {
+			return strings.EqualFold(plugin.Name, name)
+		})
+		if !ok {
+			return nil, errors.New("unknown plugin: " + name)
+		}
+		plugins[i] = plugin.Name
+	}
+	captchaSolverName := lo.Ternary(options.bypassServer == "", sydney.CaptchaSolverFail, sydney.CaptchaSolverBypass)
+	return sydney.NewSydney(sydney.Options{
+		Debug:             options.debug,
+		Cookies:           cookies,
+		Proxy:             options.proxy,
+		ConversationStyle: style.Name,
+		Locale:            options.locale,
+		NoSearch:          options.noSearch,
+		Plugins:           plugins,
+		BypassServer:      options.bypassServer,
+		CaptchaSolverName: captchaSolverName,
+		UploadCache:       sydney.NewUploadCache(util.WithPath("upload_cache.json"), sydney.DefaultUploadCacheTTL),
+	}), nil
+}
+
+// readCookies reads the cookies from a cookie string, a cookie file, or cookies.json if value is empty.
+func readCookies(value string) (map[string]string, error) {
+	if value == "" {
+		cookies, err := util.ReadCookiesFile()
+		if err != nil {
+			return nil, err
+		}
+		if len(cookies) == 0 {
+			return nil, errors.New("no cookies: pass -cookies or save them to cookies.json")
+		}
+		return cookies, nil
+	}
+	text := value
+	if v, err := os.ReadFile(value); err == nil {
+		text = string(v)
+	} else if !strings.Contains(value, "=") {
+		return nil, err
+	}
+	fileCookies, err := util.ParseCookieImport(text)
+	if err != nil {
+		return nil, err
+	}
+	fileCookies = util.FilterCookiesByDomain(fileCookies, "bing.com")
+	return lo.SliceToMap(fileCookies, func(cookie util.FileCookie) (string, string) {
+		return cookie.Name, cookie.Value
+	}), nil
+}
+func uploadImage(syd *sydney.Sydney, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	data, err = util.PrepareImage(data, util.DefaultImageOptions)
+	if err != nil {
+		return "", fmt.Errorf("cannot prepare %s: %w", path, err)
+	}
+	url, err := syd.UploadImage(data)
+	if err != nil {
+		return "", fmt.Errorf("cannot upload %s: %w", path, err)
+	}
+	return url, nil
+}
+
+// askOptions is what a turn is asked with. The image and the files are only attached to the first turn.
+type askOptions struct {
+	prompt   string
+	context  string
+	imageURL string
+	files    []string
+}
+
+// askOnce asks a turn and writes the answer, which can be stopped by Ctrl-C.
+func askOnce(syd *sydney.Sydney, session *sydney.Session, options cliOptions, ask askOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var progressMu sync.Mutex
+	ch, err := syd.AskStream(sydney.AskStreamOptions{
+		StopCtx:         ctx,
+		Session:         session,
+		Prompt:          ask.prompt,
+		WebpageContext:  ask.context,
+		ImageURL:        ask.imageURL,
+		UploadFilePaths: ask.files,
+		OnUploadProgress: func(progress sydney.UploadProgress) {
+			if options.quiet {
+				return
+			}
+			progressMu.Lock()
+			defer progressMu.Unlock()
+			_, _ = fmt.Fprintf(os.Stderr, "[upload %d/%d] %s %s\n", progress.Index+1, progress.Total,
+				progress.Path, progress.Status)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = (&renderer{out: os.Stdout, info: os.Stderr, quiet: options.quiet}).render(ch)
+	if ctx.Err() != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "[stopped]")
+		return nil
+	}
+	return err
+}
+
+// repl chats in a session until EOF or /exit. /reset starts a new conversation.
+func repl(syd *sydney.Sydney, options cliOptions, ask askOptions, prompt string) error {
+	session := sydney.NewSession()
+	_, _ = fmt.Fprintln(os.Stderr, "Chatting with Bing in the "+options.style+" style. "+
+		"Type /reset for a new conversation and /exit or Ctrl-D to quit. Ctrl-C stops an answer.")
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(nil, 1024*1024)
+	for {
+		if prompt == "" {
+			_, _ = fmt.Fprint(os.Stderr, "> ")
+			if !scanner.Scan() {
+				_, _ = fmt.Fprintln(os.Stderr)
+				return scanner.Err()
+			}
+			prompt = strings.TrimSpace(scanner.Text())
+		}
+		switch prompt {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			session.Reset()
+			_, _ = fmt.Fprintln(os.Stderr, "[new conversation]")
+			prompt = ""
+			continue
+		}
+		ask.prompt = prompt
+		if err := askOnce(syd, session, options, ask); err != nil {
+			// the image and the files stay attached to the next prompt
+			_, _ = fmt.Fprintln(os.Stderr, "error:", err)
+		} else {
+			ask.imageURL, ask.files = "", nil
+		}
+		prompt = ""
+	}
+}
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sydneyqt/sydney"
+
+	"github.com/samber/lo"
+)
+
+// renderer writes the message text of an answer to out as it streams, and the other messages to info
+// as compact one-line notes, so that out stays clean for pipes.
+type renderer struct {
+	out   io.Writer
+	info  io.Writer
+	quiet bool // no notes
+
+	midLine bool // out does not end with a newline
+}
+
+// render writes the messages of an answer until the channel is closed, and returns the error of
+// the first MessageTypeError message. The channel is still drained after it, so that the stream can finish.
+func (o *renderer) render(ch <-chan sydney.Message) error {
+	defer o.endLine()
+	var err error
+	for msg := range ch {
+		if err != nil {
+			continue
+		}
+		if msg.Type == sydney.MessageTypeError {
+			err = msg.Error
+			continue
+		}
+		if msg.Type == sydney.MessageTypeMessageText {
+			if msg.Text != "" {
+				_, _ = io.WriteString(o.out, msg.Text)
+				o.midLine = !strings.HasSuffix(msg.Text, "\n")
+			}
+			continue
+		}
+		if note := compactNote(msg); note != "" && !o.quiet {
+			o.endLine()
+			_, _ = fmt.Fprintln(o.info, note)
+		}
+	}
+	return err
+}
+func (o *renderer) endLine() {
+	if o.midLine {
+		_, _ = io.WriteString(o.out, "\n")
+		o.midLine = false
+	}
+}
+
+// compactNote renders a message other than the message text as a line like "[search] weather today",
+// or returns an empty string for those not worth showing.
+func compactNote(msg sydney.Message) string {
+	text := strings.Join(strings.Fields(msg.Text), " ")
+	switch msg.Type {
+	case sydney.MessageTypeSearchQuery:
+		return "[search] " + text
+	case sydney.MessageTypeSearchResult:
+		return "[sources] " + strings.Join(lo.Map(msg.SearchResult, func(source sydney.SourceAttribute, _ int) string {
+			return fmt.Sprintf("%d. %s <%s>", source.Index, source.Title, source.Link)
+		}), "; ")
+	case sydney.MessageTypeLoading:
+		return "[loading] " + text
+	case sydney.MessageTypeGenerativeImage:
+		return "[image] " + msg.GenerativeImage.Text + " <" + msg.GenerativeImage.URL + ">"
+	case sydney.MessageTypeGenerativeMusic:
+		return "[music] " + msg.GenerativeMusic.Text
+	case sydney.MessageTypeExecutingTask, sydney.MessageTypeOpenAPICall:
+		return "[task] " + text
+	case sydney.MessageTypeGeneratedCode:
+		lines := strings.Count(strings.TrimRight(msg.GeneratedCode.Code, "\n"), "\n") + 1
+		return fmt.Sprintf("[code] %s, %d lines", msg.GeneratedCode.Language, lines)
+	case sydney.MessageTypeCodeOutput:
+		return "[output] " + strings.Join(lo.Map(msg.CodeOutput, func(file sydney.CodeOutputFile, _ int) string {
+			return file.Name + " <" + file.URL + ">"
+		}), "; ")
+	case sydney.MessageTypeSuggestedResponses:
+		return "[suggestions] " + strings.Join(msg.SuggestedResponses, " | ")
+	case sydney.MessageTypeResolvingCaptcha:
+		return "[captcha] " + text
+	case sydney.MessageTypeContextTrimmed:
+		return "[trimmed] " + msg.ContextTrim.Description
+	}
+	return ""
+}
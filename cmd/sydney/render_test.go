@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sydneyqt/sydney"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderer(t *testing.T) {
+	ch := make(chan sydney.Message, 10)
+	ch <- sydney.Message{Type: sydney.MessageTypeSearchQuery, Text: "weather\ntoday"}
+	ch <- sydney.Message{Type: sydney.MessageTypeMessageText, Text: "It is "}
+	ch <- sydney.Message{Type: sydney.MessageTypeSearchResult, SearchResult: []sydney.SourceAttribute{
+		{Index: 1, Title: "Weather", Link: "https://example.com/weather"},
+	}}
+	ch <- sydney.Message{Type: sydney.MessageTypeMessageText, Text: "sunny."}
+	ch <- sydney.Message{Type: sydney.MessageTypeGeneratedCode, Text: "a = 1\nprint(a)\n",
+		GeneratedCode: &sydney.GeneratedCode{Code: "a = 1\nprint(a)\n", Language: "python"}}
+	ch <- sydney.Message{Type: sydney.MessageTypeSuggestedResponses, SuggestedResponses: []string{"Tomorrow?", "Why?"}}
+	close(ch)
+	var out, info strings.Builder
+	assert.Nil(t, (&renderer{out: &out, info: &info}).render(ch))
+	assert.Equal(t, "It is \nsunny.\n", out.String())
+	assert.Equal(t, "[search] weather today\n"+
+		"[sources] 1. Weather <https://example.com/weather>\n"+
+		"[code] python, 2 lines\n"+
+		"[suggestions] Tomorrow? | Why?\n", info.String())
+
+	ch = make(chan sydney.Message, 10)
+	ch <- sydney.Message{Type: sydney.MessageTypeLoading, Text: "Generating answers for you..."}
+	ch <- sydney.Message{Type: sydney.MessageTypeMessageText, Text: "Once"}
+	ch <- sydney.Message{Type: sydney.MessageTypeError, Text: "revoked", Error: sydney.ErrMessageRevoke}
+	ch <- sydney.Message{Type: sydney.MessageTypeMessageText, Text: " after the error"}
+	ch <- sydney.Message{Type: sydney.MessageTypeError, Text: "network", Error: sydney.ErrNetwork}
+	close(ch)
+	out.Reset()
+	info.Reset()
+	err := (&renderer{out: &out, info: &info, quiet: true}).render(ch)
+	assert.True(t, errors.Is(err, sydney.ErrMessageRevoke))
+	assert.Equal(t, "Once\n", out.String())
+	assert.Empty(t, info.String())
+	assert.Empty(t, ch, "the channel is drained")
+}
+
+func TestReadCookies(t *testing.T) {
+	cookies, err := readCookies("_U=abc; SRCHHPGUSR=def")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"_U": "abc", "SRCHHPGUSR": "def"}, cookies)
+
+	file := filepath.Join(t.TempDir(), "cookies.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"name": "_U", "value": "abc", "domain": ".bing.com"},
+		{"name": "SID", "value": "x", "domain": ".google.com"}]`), 0644))
+	cookies, err = readCookies(file)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"_U": "abc"}, cookies)
+
+	_, err = readCookies(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"os"
+	"sydneyqt/sydney"
 	"sydneyqt/util"
 	"sync"
 	"time"
@@ -32,6 +33,8 @@ type Workspace struct {
 	Plugins           []string        `json:"plugins"`
 	DataReferences    []DataReference `json:"data_references"`
 	Model             string          `json:"model"`
+	ReuseConversation bool            `json:"reuse_conversation"`
+	Location          sydney.Location `json:"location"`
 }
 type DataReference struct {
 	UUID string `json:"uuid"`
@@ -61,6 +64,8 @@ type Config struct {
 	StretchFactor                 int             `json:"stretch_factor"`
 	RevokeReplyText               string          `json:"revoke_reply_text"`
 	RevokeReplyCount              int             `json:"revoke_reply_count"`
+	ContextTokenBudget            int             `json:"context_token_budget"`
+	ContextTrimStrategy           string          `json:"context_trim_strategy"`
 	Workspaces                    []Workspace     `json:"workspaces"`
 	CurrentWorkspaceID            int             `json:"current_workspace_id"`
 	Quick                         []string        `json:"quick"`
@@ -74,8 +79,21 @@ type Config struct {
 	ThemeColor                    string          `json:"theme_color"`
 	DisableNoSearchLoader         bool            `json:"disable_no_search_loader"`
 	BypassServer                  string          `json:"bypass_server"`
+	CaptchaSolver                 string          `json:"captcha_solver"`
 	DisableSummaryTitleGeneration bool            `json:"disable_summary_title_generation"`
 	HideUserStatusButton          bool            `json:"hide_user_status_button"`
+	DebugTranscript               bool            `json:"debug_transcript"`
+	SaveGeneratedImages           bool            `json:"save_generated_images"`
+	SaveGeneratedMusic            bool            `json:"save_generated_music"`
+	ImageMaxDimension             int             `json:"image_max_dimension"`
+	ImageMaxSize                  int             `json:"image_max_size"`  // in KiB
+	UploadMaxSize                 int             `json:"upload_max_size"` // in MiB
+	DisableUploadCache            bool            `json:"disable_upload_cache"`
+	KeepaliveInterval             int             `json:"keepalive_interval"` // in seconds
+	ReadTimeout                   int             `json:"read_timeout"`       // in seconds
+	IdleTimeout                   int             `json:"idle_timeout"`       // in seconds
+	PollInterval                  int             `json:"poll_interval"`      // in seconds
+	PollAttempts                  int             `json:"poll_attempts"`
 
 	Migration Migration `json:"migration"`
 }
@@ -157,6 +175,7 @@ func (o *Config) FillDefault() {
 	fillDefault(&o.FontSize, 16)
 	fillDefault(&o.StretchFactor, 20)
 	fillDefault(&o.RevokeReplyText, "Continue from where you stopped.")
+	fillDefault(&o.ContextTrimStrategy, sydney.TrimStrategyDropOldest)
 	if len(o.Quick) == 0 {
 		o.Quick = []string{"Continue from where you stopped.", "Translate the text above into English.",
 			"Explain the content above in a comprehensive but simple way.",
@@ -182,6 +201,14 @@ func (o *Config) FillDefault() {
 	fillDefault(&o.WssDomain, "sydney.bing.com")
 	fillDefault(&o.CreateConversationURL, "https://edgeservices.bing.com/edgesvc/turing/conversation/create")
 	fillDefault(&o.ThemeColor, "#00B8FF")
+	fillDefault(&o.ImageMaxDimension, util.DefaultImageOptions.MaxDimension)
+	fillDefault(&o.ImageMaxSize, util.DefaultImageOptions.MaxBytes/1024)
+	fillDefault(&o.UploadMaxSize, sydney.DefaultUploadMaxSize>>20)
+	fillDefault(&o.KeepaliveInterval, int(sydney.DefaultKeepaliveInterval/time.Second))
+	fillDefault(&o.ReadTimeout, int(sydney.DefaultReadTimeout/time.Second))
+	fillDefault(&o.IdleTimeout, int(sydney.DefaultIdleTimeout/time.Second))
+	fillDefault(&o.PollInterval, int(sydney.DefaultPollInterval/time.Second))
+	fillDefault(&o.PollAttempts, sydney.DefaultPollAttempts)
 }
 
 type Settings struct {
@@ -231,6 +258,9 @@ func (o *Settings) SetConfig(config Config) {
 	if o.config.Debug != config.Debug {
 		o.DebugChangeSignal <- config.Debug
 	}
+	if o.config.Proxy != config.Proxy {
+		util.ResetHTTPClients()
+	}
 	o.config = config
 	o.version++
 }
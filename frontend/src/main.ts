@@ -12,6 +12,7 @@ import {aliases, mdi} from "vuetify/iconsets/mdi"
 import IndexPage from "./pages/IndexPage.vue"
 import * as VueRouter from 'vue-router'
 import SettingsPage from "./pages/SettingsPage.vue"
+import GalleryPage from "./pages/GalleryPage.vue"
 
 const vuetify = createVuetify({
     components,
@@ -42,7 +43,8 @@ const vuetify = createVuetify({
 })
 const routes = [
     {path: '/', component: IndexPage},
-    {path: '/settings', component: SettingsPage}
+    {path: '/settings', component: SettingsPage},
+    {path: '/gallery', component: GalleryPage},
 ]
 const router = VueRouter.createRouter({
     history: VueRouter.createWebHashHistory(),
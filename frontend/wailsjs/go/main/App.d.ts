@@ -4,12 +4,22 @@ import {main} from '../models';
 import {sydney} from '../models';
 import {util} from '../models';
 
+export function AddMusicToLibrary(arg1:sydney.GenerateMusicResult):Promise<util.MusicTrack>;
+
 export function AskAI(arg1:main.AskOptions):Promise<void>;
 
 export function CheckUpdate():Promise<main.CheckUpdateResult>;
 
+export function ClearUploadCache():Promise<void>;
+
 export function CountToken(arg1:string):Promise<number>;
 
+export function DeleteArtifact(arg1:string):Promise<void>;
+
+export function DeleteGalleryImage(arg1:string):Promise<void>;
+
+export function DeleteMusicTrack(arg1:string):Promise<void>;
+
 export function Dummy1():Promise<main.ChatFinishResult>;
 
 export function ExportWorkspace(arg1:number):Promise<void>;
@@ -22,19 +32,51 @@ export function GenerateMusic(arg1:sydney.GenerativeMusic):Promise<sydney.Genera
 
 export function GetConciseAnswer(arg1:main.ConciseAnswerReq):Promise<string>;
 
+export function GetConversationStyles():Promise<Array<sydney.ConversationStyle>>;
+
+export function GetCookieExpiry():Promise<Array<util.CookieExpiry>>;
+
+export function GetLocations():Promise<Array<sydney.Location>>;
+
+export function GetMusicStyles():Promise<Array<string>>;
+
+export function GetPlugins():Promise<Array<sydney.Plugin>>;
+
 export function GetUser():Promise<string>;
 
 export function GetYoutubeTranscript(arg1:util.YtCustomCaption):Promise<Array<util.YtTranscriptText>>;
 
 export function GetYoutubeVideo(arg1:string):Promise<main.YoutubeVideoResult>;
 
+export function ImportCookies(arg1:string):Promise<number>;
+
+export function ImportCookiesFromFile():Promise<number>;
+
+export function ListArtifacts(arg1:number):Promise<Array<util.Artifact>>;
+
+export function ListGalleryImages():Promise<Array<util.GalleryImage>>;
+
+export function OpenArtifactWorkspace(arg1:string):Promise<void>;
+
+export function OpenGalleryImageWorkspace(arg1:string):Promise<void>;
+
+export function OpenMusicTrackWorkspace(arg1:string):Promise<void>;
+
+export function SaveArtifact(arg1:string):Promise<void>;
+
+export function SaveArtifactsAsZip(arg1:number):Promise<void>;
+
 export function SaveRemoteFile(arg1:string,arg2:string,arg3:string):Promise<void>;
 
 export function SaveRemoteJPEGImage(arg1:string):Promise<void>;
 
 export function SaveTempFileToUploadFromBase64(arg1:string,arg2:string):Promise<string>;
 
-export function SelectUploadFile():Promise<string>;
+export function SearchGalleryImages(arg1:string):Promise<Array<util.GalleryImage>>;
+
+export function SearchMusicTracks(arg1:util.MusicQuery):Promise<Array<util.MusicTrack>>;
+
+export function SelectUploadFiles():Promise<Array<string>>;
 
 export function ShareWorkspace(arg1:number):Promise<void>;
 
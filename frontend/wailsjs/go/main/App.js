@@ -2,6 +2,10 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddMusicToLibrary(arg1) {
+  return window['go']['main']['App']['AddMusicToLibrary'](arg1);
+}
+
 export function AskAI(arg1) {
   return window['go']['main']['App']['AskAI'](arg1);
 }
@@ -10,10 +14,26 @@ export function CheckUpdate() {
   return window['go']['main']['App']['CheckUpdate']();
 }
 
+export function ClearUploadCache() {
+  return window['go']['main']['App']['ClearUploadCache']();
+}
+
 export function CountToken(arg1) {
   return window['go']['main']['App']['CountToken'](arg1);
 }
 
+export function DeleteArtifact(arg1) {
+  return window['go']['main']['App']['DeleteArtifact'](arg1);
+}
+
+export function DeleteGalleryImage(arg1) {
+  return window['go']['main']['App']['DeleteGalleryImage'](arg1);
+}
+
+export function DeleteMusicTrack(arg1) {
+  return window['go']['main']['App']['DeleteMusicTrack'](arg1);
+}
+
 export function Dummy1() {
   return window['go']['main']['App']['Dummy1']();
 }
@@ -38,6 +58,26 @@ export function GetConciseAnswer(arg1) {
   return window['go']['main']['App']['GetConciseAnswer'](arg1);
 }
 
+export function GetConversationStyles() {
+  return window['go']['main']['App']['GetConversationStyles']();
+}
+
+export function GetCookieExpiry() {
+  return window['go']['main']['App']['GetCookieExpiry']();
+}
+
+export function GetLocations() {
+  return window['go']['main']['App']['GetLocations']();
+}
+
+export function GetMusicStyles() {
+  return window['go']['main']['App']['GetMusicStyles']();
+}
+
+export function GetPlugins() {
+  return window['go']['main']['App']['GetPlugins']();
+}
+
 export function GetUser() {
   return window['go']['main']['App']['GetUser']();
 }
@@ -50,6 +90,42 @@ export function GetYoutubeVideo(arg1) {
   return window['go']['main']['App']['GetYoutubeVideo'](arg1);
 }
 
+export function ImportCookies(arg1) {
+  return window['go']['main']['App']['ImportCookies'](arg1);
+}
+
+export function ImportCookiesFromFile() {
+  return window['go']['main']['App']['ImportCookiesFromFile']();
+}
+
+export function ListArtifacts(arg1) {
+  return window['go']['main']['App']['ListArtifacts'](arg1);
+}
+
+export function ListGalleryImages() {
+  return window['go']['main']['App']['ListGalleryImages']();
+}
+
+export function OpenArtifactWorkspace(arg1) {
+  return window['go']['main']['App']['OpenArtifactWorkspace'](arg1);
+}
+
+export function OpenGalleryImageWorkspace(arg1) {
+  return window['go']['main']['App']['OpenGalleryImageWorkspace'](arg1);
+}
+
+export function OpenMusicTrackWorkspace(arg1) {
+  return window['go']['main']['App']['OpenMusicTrackWorkspace'](arg1);
+}
+
+export function SaveArtifact(arg1) {
+  return window['go']['main']['App']['SaveArtifact'](arg1);
+}
+
+export function SaveArtifactsAsZip(arg1) {
+  return window['go']['main']['App']['SaveArtifactsAsZip'](arg1);
+}
+
 export function SaveRemoteFile(arg1, arg2, arg3) {
   return window['go']['main']['App']['SaveRemoteFile'](arg1, arg2, arg3);
 }
@@ -62,8 +138,16 @@ export function SaveTempFileToUploadFromBase64(arg1, arg2) {
   return window['go']['main']['App']['SaveTempFileToUploadFromBase64'](arg1, arg2);
 }
 
-export function SelectUploadFile() {
-  return window['go']['main']['App']['SelectUploadFile']();
+export function SearchGalleryImages(arg1) {
+  return window['go']['main']['App']['SearchGalleryImages'](arg1);
+}
+
+export function SearchMusicTracks(arg1) {
+  return window['go']['main']['App']['SearchMusicTracks'](arg1);
+}
+
+export function SelectUploadFiles() {
+  return window['go']['main']['App']['SelectUploadFiles']();
 }
 
 export function ShareWorkspace(arg1) {
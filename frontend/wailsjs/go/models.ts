@@ -6,7 +6,7 @@ export namespace main {
 	    chat_context: string;
 	    prompt: string;
 	    image_url: string;
-	    upload_file_path: string;
+	    upload_file_paths: string[];
 	    model: string;
 	
 	    static createFrom(source: any = {}) {
@@ -20,7 +20,7 @@ export namespace main {
 	        this.chat_context = source["chat_context"];
 	        this.prompt = source["prompt"];
 	        this.image_url = source["image_url"];
-	        this.upload_file_path = source["upload_file_path"];
+	        this.upload_file_paths = source["upload_file_paths"];
 	        this.model = source["model"];
 	    }
 	}
@@ -158,6 +158,8 @@ export namespace main {
 	    plugins: string[];
 	    data_references: DataReference[];
 	    model: string;
+	    reuse_conversation: boolean;
+	    location: sydney.Location;
 	
 	    static createFrom(source: any = {}) {
 	        return new Workspace(source);
@@ -181,6 +183,8 @@ export namespace main {
 	        this.plugins = source["plugins"];
 	        this.data_references = this.convertValues(source["data_references"], DataReference);
 	        this.model = source["model"];
+	        this.reuse_conversation = source["reuse_conversation"];
+	        this.location = this.convertValues(source["location"], sydney.Location);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -226,6 +230,8 @@ export namespace main {
 	    stretch_factor: number;
 	    revoke_reply_text: string;
 	    revoke_reply_count: number;
+	    context_token_budget: number;
+	    context_trim_strategy: string;
 	    workspaces: Workspace[];
 	    current_workspace_id: number;
 	    quick: string[];
@@ -239,8 +245,21 @@ export namespace main {
 	    theme_color: string;
 	    disable_no_search_loader: boolean;
 	    bypass_server: string;
+	    captcha_solver: string;
 	    disable_summary_title_generation: boolean;
 	    hide_user_status_button: boolean;
+	    debug_transcript: boolean;
+	    save_generated_images: boolean;
+	    save_generated_music: boolean;
+	    image_max_dimension: number;
+	    image_max_size: number;
+	    upload_max_size: number;
+	    disable_upload_cache: boolean;
+	    keepalive_interval: number;
+	    read_timeout: number;
+	    idle_timeout: number;
+	    poll_interval: number;
+	    poll_attempts: number;
 	    migration: Migration;
 	
 	    static createFrom(source: any = {}) {
@@ -259,6 +278,8 @@ export namespace main {
 	        this.stretch_factor = source["stretch_factor"];
 	        this.revoke_reply_text = source["revoke_reply_text"];
 	        this.revoke_reply_count = source["revoke_reply_count"];
+	        this.context_token_budget = source["context_token_budget"];
+	        this.context_trim_strategy = source["context_trim_strategy"];
 	        this.workspaces = this.convertValues(source["workspaces"], Workspace);
 	        this.current_workspace_id = source["current_workspace_id"];
 	        this.quick = source["quick"];
@@ -272,8 +293,21 @@ export namespace main {
 	        this.theme_color = source["theme_color"];
 	        this.disable_no_search_loader = source["disable_no_search_loader"];
 	        this.bypass_server = source["bypass_server"];
+	        this.captcha_solver = source["captcha_solver"];
 	        this.disable_summary_title_generation = source["disable_summary_title_generation"];
 	        this.hide_user_status_button = source["hide_user_status_button"];
+	        this.debug_transcript = source["debug_transcript"];
+	        this.save_generated_images = source["save_generated_images"];
+	        this.save_generated_music = source["save_generated_music"];
+	        this.image_max_dimension = source["image_max_dimension"];
+	        this.image_max_size = source["image_max_size"];
+	        this.upload_max_size = source["upload_max_size"];
+	        this.disable_upload_cache = source["disable_upload_cache"];
+	        this.keepalive_interval = source["keepalive_interval"];
+	        this.read_timeout = source["read_timeout"];
+	        this.idle_timeout = source["idle_timeout"];
+	        this.poll_interval = source["poll_interval"];
+	        this.poll_attempts = source["poll_attempts"];
 	        this.migration = this.convertValues(source["migration"], Migration);
 	    }
 	
@@ -391,6 +425,32 @@ export namespace main {
 
 export namespace sydney {
 	
+	export class ConversationStyle {
+	    name: string;
+	    description: string;
+	    tone: string;
+	    classic_tone?: string;
+	    options_sets: string[];
+	    gpt_id?: string;
+	    gpt4_turbo: boolean;
+	    models?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ConversationStyle(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.tone = source["tone"];
+	        this.classic_tone = source["classic_tone"];
+	        this.options_sets = source["options_sets"];
+	        this.gpt_id = source["gpt_id"];
+	        this.gpt4_turbo = source["gpt4_turbo"];
+	        this.models = source["models"];
+	    }
+	}
 	export class GenerateImageResult {
 	    text: string;
 	    url: string;
@@ -471,11 +531,277 @@ export namespace sydney {
 	        this.text = source["text"];
 	    }
 	}
+	export class Location {
+	    name: string;
+	    city: string;
+	    admin1_name: string;
+	    country_name: string;
+	    country_code: string;
+	    market: string;
+	    latitude: number;
+	    longitude: number;
+	    utc_offset: number;
+	    post_code?: string;
+	    dma?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Location(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.city = source["city"];
+	        this.admin1_name = source["admin1_name"];
+	        this.country_name = source["country_name"];
+	        this.country_code = source["country_code"];
+	        this.market = source["market"];
+	        this.latitude = source["latitude"];
+	        this.longitude = source["longitude"];
+	        this.utc_offset = source["utc_offset"];
+	        this.post_code = source["post_code"];
+	        this.dma = source["dma"];
+	    }
+	}
+	export class Plugin {
+	    name: string;
+	    description: string;
+	    options_sets: string[];
+	    id: string;
+	    category: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Plugin(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.options_sets = source["options_sets"];
+	        this.id = source["id"];
+	        this.category = source["category"];
+	    }
+	}
 
 }
 
 export namespace util {
 	
+	export class ArtifactFile {
+	    name: string;
+	    url: string;
+	    kind: string;
+	    file: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ArtifactFile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.url = source["url"];
+	        this.kind = source["kind"];
+	        this.file = source["file"];
+	    }
+	}
+	export class Artifact {
+	    id: string;
+	    workspace_id: number;
+	    prompt: string;
+	    // Go type: time
+	    created_at: any;
+	    code: string;
+	    language: string;
+	    code_file: string;
+	    files: ArtifactFile[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Artifact(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.workspace_id = source["workspace_id"];
+	        this.prompt = source["prompt"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.code = source["code"];
+	        this.language = source["language"];
+	        this.code_file = source["code_file"];
+	        this.files = this.convertValues(source["files"], ArtifactFile);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CookieExpiry {
+	    name: string;
+	    // Go type: time
+	    expires_at: any;
+	    expired: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new CookieExpiry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.expires_at = this.convertValues(source["expires_at"], null);
+	        this.expired = source["expired"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class GalleryImage {
+	    id: string;
+	    prompt: string;
+	    workspace_id: number;
+	    // Go type: time
+	    created_at: any;
+	    source_url: string;
+	    duration: number;
+	    file_name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new GalleryImage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.prompt = source["prompt"];
+	        this.workspace_id = source["workspace_id"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.source_url = source["source_url"];
+	        this.duration = source["duration"];
+	        this.file_name = source["file_name"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MusicQuery {
+	    text: string;
+	    style: string;
+	    workspace_id: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MusicQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.text = source["text"];
+	        this.style = source["style"];
+	        this.workspace_id = source["workspace_id"];
+	    }
+	}
+	export class MusicTrack {
+	    id: string;
+	    title: string;
+	    musical_style: string;
+	    lyrics: string;
+	    prompt: string;
+	    workspace_id: number;
+	    // Go type: time
+	    created_at: any;
+	    duration: number;
+	    music_url: string;
+	    video_url: string;
+	    cover_img_url: string;
+	    audio_file: string;
+	    video_file: string;
+	    cover_img_file: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MusicTrack(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.musical_style = source["musical_style"];
+	        this.lyrics = source["lyrics"];
+	        this.prompt = source["prompt"];
+	        this.workspace_id = source["workspace_id"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.duration = source["duration"];
+	        this.music_url = source["music_url"];
+	        this.video_url = source["video_url"];
+	        this.cover_img_url = source["cover_img_url"];
+	        this.audio_file = source["audio_file"];
+	        this.video_file = source["video_file"];
+	        this.cover_img_file = source["cover_img_file"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class YtCustomCaption {
 	    name: string;
 	    language_code: string;
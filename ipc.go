@@ -7,7 +7,6 @@ import (
 	"github.com/go-chi/cors"
 	"log/slog"
 	"net/http"
-	"os"
 	"sydneyqt/util"
 )
 
@@ -41,8 +40,7 @@ func (o *IPCServer) registerRouters(mux *chi.Mux) {
 			slog.Error("Could not decode request", "err", err)
 			return
 		}
-		v, _ := json.MarshalIndent(&cookies, "", "  ")
-		err = os.WriteFile(util.WithPath("cookies.json"), v, 0644)
+		err = util.WriteCookiesFile(cookies)
 		if err != nil {
 			writer.WriteHeader(500)
 			slog.Error("Could write cookies.json", "err", err)
@@ -10,16 +10,124 @@ import (
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/go-rod/stealth"
 	"github.com/google/uuid"
+	"github.com/samber/lo"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sydneyqt/sydney/internal/hex"
 	"sydneyqt/util"
+	"sync"
 	"time"
 )
 
-func (o *Sydney) ResolveCaptcha(stopCtx context.Context) (err error) {
+// CaptchaRequest describes the CAPTCHA Bing asks for in a turn.
+type CaptchaRequest struct {
+	Cookies        map[string]string // a copy of the cookies of the account
+	Proxy          string
+	ConversationID string
+	MessageID      string
+}
+
+// CaptchaSolver solves the CAPTCHA Bing asks for, returning the cookies to be updated,
+// which must contain cct.
+type CaptchaSolver interface {
+	SolveCaptcha(ctx context.Context, request CaptchaRequest) (map[string]string, error)
+}
+
+// CaptchaSolverFunc adapts a function to CaptchaSolver.
+type CaptchaSolverFunc func(ctx context.Context, request CaptchaRequest) (map[string]string, error)
+
+func (f CaptchaSolverFunc) SolveCaptcha(ctx context.Context, request CaptchaRequest) (map[string]string, error) {
+	return f(ctx, request)
+}
+
+const (
+	CaptchaSolverBrowser = "browser"
+	CaptchaSolverBypass  = "bypass"
+	CaptchaSolverFail    = "fail"
+)
+
+var (
+	captchaSolversMu sync.RWMutex
+	captchaSolvers   = map[string]func(options Options) CaptchaSolver{
+		CaptchaSolverBrowser: func(options Options) CaptchaSolver {
+			return BrowserCaptchaSolver{}
+		},
+		CaptchaSolverBypass: func(options Options) CaptchaSolver {
+			return BypassCaptchaSolver{Server: options.BypassServer}
+		},
+		CaptchaSolverFail: func(options Options) CaptchaSolver {
+			return FailCaptchaSolver{}
+		},
+	}
+)
+
+// RegisterCaptchaSolver makes a custom solver selectable by name in Options.CaptchaSolverName.
+// The factory is called by NewSydney with its options.
+func RegisterCaptchaSolver(name string, factory func(options Options) CaptchaSolver) {
+	captchaSolversMu.Lock()
+	defer captchaSolversMu.Unlock()
+	captchaSolvers[name] = factory
+}
+
+// CaptchaSolverNames returns the names of the registered solvers, sorted.
+func CaptchaSolverNames() []string {
+	captchaSolversMu.RLock()
+	defer captchaSolversMu.RUnlock()
+	names := lo.Keys(captchaSolvers)
+	sort.Strings(names)
+	return names
+}
+
+// newCaptchaSolver returns options.CaptchaSolver if set, or the solver named options.CaptchaSolverName,
+// which defaults to CaptchaSolverBypass if options.BypassServer is set and CaptchaSolverBrowser otherwise.
+func newCaptchaSolver(options Options) CaptchaSolver {
+	if options.CaptchaSolver != nil {
+		return options.CaptchaSolver
+	}
+	name := options.CaptchaSolverName
+	if name == "" {
+		name = lo.Ternary(options.BypassServer == "", CaptchaSolverBrowser, CaptchaSolverBypass)
+	}
+	captchaSolversMu.RLock()
+	factory, ok := captchaSolvers[name]
+	captchaSolversMu.RUnlock()
+	if !ok {
+		slog.Warn("Unknown CAPTCHA solver", "name", name)
+		return CaptchaSolverFunc(func(ctx context.Context, request CaptchaRequest) (map[string]string, error) {
+			return nil, errors.New("unknown CAPTCHA solver: " + name)
+		})
+	}
+	return factory(options)
+}
+
+// solveCaptcha solves the CAPTCHA with the solver of the options and saves the cookies received.
+func (o *Sydney) solveCaptcha(ctx context.Context, conversationID string, messageID string) error {
+	cookies, err := o.captchaSolver.SolveCaptcha(ctx, CaptchaRequest{
+		Cookies:        util.CopyMap(o.cookies),
+		Proxy:          o.proxy,
+		ConversationID: conversationID,
+		MessageID:      messageID,
+	})
+	if err != nil {
+		return err
+	}
+	return o.postprocessCaptchaCookies(cookies)
+}
+
+// FailCaptchaSolver does not solve the CAPTCHA, for deployments where it has to be solved manually.
+type FailCaptchaSolver struct{}
+
+func (FailCaptchaSolver) SolveCaptcha(ctx context.Context, request CaptchaRequest) (map[string]string, error) {
+	return nil, classify(ErrCaptchaRequired, errors.New("automatic CAPTCHA solving is disabled"))
+}
+
+// BrowserCaptchaSolver opens the CAPTCHA in a visible Chrome, where it may also be solved by the user.
+type BrowserCaptchaSolver struct{}
+
+func (BrowserCaptchaSolver) SolveCaptcha(stopCtx context.Context, request CaptchaRequest) (resCookies map[string]string, err error) {
 	defer func() {
 		if err0 := recover(); err0 != nil {
 			slog.Warn("Error resolving captcha", "err", err0)
@@ -37,7 +145,7 @@ func (o *Sydney) ResolveCaptcha(stopCtx context.Context) (err error) {
 	browser := rod.New().Context(stopCtx).NoDefaultDevice().ControlURL(u).MustConnect()
 	defer browser.MustClose()
 	var cookies []*proto.NetworkCookie
-	for k, v := range o.cookies {
+	for k, v := range request.Cookies {
 		cookies = append(cookies, &proto.NetworkCookie{
 			Name:    k,
 			Value:   v,
@@ -57,7 +165,6 @@ func (o *Sydney) ResolveCaptcha(stopCtx context.Context) (err error) {
 	router := page.HijackRequests()
 	waitCh := make(chan struct{}, 16)
 	defer close(waitCh)
-	var resCookies map[string]string
 	router.MustAdd("https://www.bing.com/challenge/verify*", func(hijack *rod.Hijack) {
 		hijack.MustLoadResponse()
 		for key, values := range hijack.Response.Headers() {
@@ -76,61 +183,68 @@ func (o *Sydney) ResolveCaptcha(stopCtx context.Context) (err error) {
 	defer router.Stop()
 	select {
 	case <-time.Tick(60 * time.Second):
-		return errors.New("timeout verifying challenge token")
+		return nil, errors.New("timeout verifying challenge token")
 	case <-stopCtx.Done():
-		return stopCtx.Err()
+		return nil, stopCtx.Err()
 	case <-waitCh:
 	}
 	slog.Info("Captcha resCookies", "v", resCookies)
-	if err := o.postprocessCaptchaCookies(resCookies); err != nil {
-		return err
-	}
-	return nil
+	return resCookies, nil
 }
-func (o *Sydney) BypassCaptcha(stopCtx context.Context, conversationID string, messageID string) error {
-	if o.bypassServer == "" {
-		return errors.New("no bypass server specified")
+
+// BypassCaptchaSolver asks a bypass server to solve the CAPTCHA.
+type BypassCaptchaSolver struct {
+	Server string
+}
+
+func (o BypassCaptchaSolver) SolveCaptcha(stopCtx context.Context, request CaptchaRequest) (map[string]string, error) {
+	if o.Server == "" {
+		return nil, errors.New("no bypass server specified")
 	}
-	_, client, err := util.MakeHTTPClient(o.proxy, 60*time.Second)
+	_, client, err := util.MakeHTTPClient(request.Proxy, 60*time.Second)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req := BypassCaptchaRequest{
 		IG:       hex.NewUpperHex(32),
-		Cookies:  util.FormatCookieString(o.cookies),
+		Cookies:  util.FormatCookieString(request.Cookies),
 		IFrameID: "local-gen-" + uuid.New().String(),
-		ConvID:   conversationID,
-		RID:      messageID,
+		ConvID:   request.ConversationID,
+		RID:      request.MessageID,
 	}
 	slog.Debug("Bypass CAPTCHA request", "v", req)
-	resp, err := client.R().SetContext(stopCtx).SetBody(req).Post(o.bypassServer)
+	resp, err := client.R().SetContext(stopCtx).SetBody(req).Post(o.Server)
 	if err != nil {
-		return fmt.Errorf("cannot communicate with captcha bypass server: %w", err)
+		return nil, fmt.Errorf("cannot communicate with captcha bypass server: %w", err)
 	}
 	slog.Debug("Bypass captcha response body", "v", resp.String())
 	var response BypassCaptchaResponse
 	err = json.Unmarshal(resp.Bytes(), &response)
 	if err != nil {
-		return fmt.Errorf("cannot unmarshal json from captcha bypass server: %w", err)
+		return nil, fmt.Errorf("cannot unmarshal json from captcha bypass server: %w", err)
 	}
 	if response.Error != "" {
-		return errors.New("bypass captcha error: " + response.Error)
+		return nil, errors.New("bypass captcha error: " + response.Error)
 	}
 	cookies := util.ParseCookiesFromString(response.Result.Cookies)
-	if err := o.postprocessCaptchaCookies(cookies); err != nil {
-		return fmt.Errorf("%w; screenshot: "+
-			strings.TrimSuffix(o.bypassServer, "/")+
-			response.Result.ScreenShot, err)
+	if _, ok := cookies["cct"]; !ok {
+		return nil, errors.New("captcha cookies not valid: no cookie named cct found; screenshot: " +
+			strings.TrimSuffix(o.Server, "/") + response.Result.ScreenShot)
 	}
-	return nil
+	return cookies, nil
 }
+
+// UpdateModifiedCookies sets the cookies modified by Bing or the CAPTCHA solver, and passes all the cookies
+// to Options.OnCookiesUpdated.
 func (o *Sydney) UpdateModifiedCookies(modifiedCookies map[string]string) {
+	if len(modifiedCookies) == 0 {
+		return
+	}
 	for k, v := range modifiedCookies { // keep the map pointer
 		o.cookies[k] = v
 	}
-	err := util.UpdateCookiesFile(o.cookies)
-	if err != nil {
-		slog.Warn("Cannot update cookies file: ", "err", err)
+	if o.onCookiesUpdated != nil {
+		o.onCookiesUpdated(util.CopyMap(o.cookies))
 	}
 }
 func (o *Sydney) postprocessCaptchaCookies(modifiedCookies map[string]string) error {
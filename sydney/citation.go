@@ -0,0 +1,128 @@
+package sydney
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// citationRegexp matches Bing's footnote markers, e.g. [^1^] and (^1^).
+var citationRegexp = regexp.MustCompile(`\[\^(\d+)\^]|\(\^(\d+)\^\)`)
+
+// partialCitationRegexp matches the beginning of a footnote marker at the end of a text.
+var partialCitationRegexp = regexp.MustCompile(`[\[(](\^\d*\^?)?$`)
+
+var markdownLinkTextReplacer = strings.NewReplacer("[", `\[`, "]", `\]`)
+
+// RewriteCitations replaces the footnote markers in text with markdown links to the sources,
+// and appends a references section if any of the sources is cited.
+func RewriteCitations(text string, sources []SourceAttribute) string {
+	text, cited := rewriteCitationMarkers(text, sources)
+	if cited {
+		text += formatReferences(sources)
+	}
+	return text
+}
+func rewriteCitationMarkers(text string, sources []SourceAttribute) (string, bool) {
+	cited := false
+	text = citationRegexp.ReplaceAllStringFunc(text, func(marker string) string {
+		matches := citationRegexp.FindStringSubmatch(marker)
+		index, _ := strconv.Atoi(matches[1] + matches[2])
+		i := slices.IndexFunc(sources, func(source SourceAttribute) bool {
+			return source.Index == index
+		})
+		if i == -1 {
+			return marker
+		}
+		cited = true
+		if matches[1] != "" {
+			return "[[" + strconv.Itoa(index) + "]](" + sources[i].Link + ")"
+		}
+		return "(" + sources[i].Link + ")"
+	})
+	return text, cited
+}
+func formatReferences(sources []SourceAttribute) string {
+	sorted := slices.Clone(sources)
+	slices.SortStableFunc(sorted, func(a, b SourceAttribute) int {
+		return a.Index - b.Index
+	})
+	var sb strings.Builder
+	sb.WriteString("\n\nReferences:")
+	for _, source := range sorted {
+		sb.WriteString(fmt.Sprintf("\n%d. [%s](%s)", source.Index,
+			markdownLinkTextReplacer.Replace(source.Title), source.Link))
+	}
+	return sb.String()
+}
+
+// mergeSources adds sources to the existing ones, replacing those with the same index.
+func mergeSources(existing []SourceAttribute, sources []SourceAttribute) []SourceAttribute {
+	for _, source := range sources {
+		i := slices.IndexFunc(existing, func(item SourceAttribute) bool {
+			return item.Index == source.Index
+		})
+		if i == -1 {
+			existing = append(existing, source)
+		} else {
+			existing[i] = source
+		}
+	}
+	return existing
+}
+
+// rewriteCitationsInStream works like RewriteCitations on the message text of ch.
+// A marker split across messages is held back until it is complete, and the references
+// are sent before the suggested responses, an error or the end of the stream.
+func rewriteCitationsInStream(ch <-chan Message) <-chan Message {
+	out := make(chan Message)
+	go func() {
+		defer close(out)
+		var sources []SourceAttribute
+		pending := ""
+		cited := false
+		referencesSent := false
+		flushText := func() {
+			if pending != "" {
+				out <- Message{Type: MessageTypeMessageText, Text: pending}
+				pending = ""
+			}
+		}
+		flushReferences := func() {
+			flushText()
+			if cited && !referencesSent {
+				out <- Message{Type: MessageTypeMessageText, Text: formatReferences(sources)}
+				referencesSent = true
+			}
+		}
+		for msg := range ch {
+			switch msg.Type {
+			case MessageTypeMessageText:
+				text, ok := rewriteCitationMarkers(pending+msg.Text, sources)
+				cited = cited || ok
+				i := partialCitationRegexp.FindStringIndex(text)
+				if i != nil {
+					text, pending = text[:i[0]], text[i[0]:]
+				} else {
+					pending = ""
+				}
+				if text != "" {
+					out <- Message{Type: MessageTypeMessageText, Text: text}
+				}
+				continue
+			case MessageTypeSearchResult:
+				flushText()
+				sources = mergeSources(sources, msg.SearchResult)
+			case MessageTypeSuggestedResponses, MessageTypeError:
+				flushReferences()
+			default:
+				flushText()
+			}
+			out <- msg
+		}
+		flushReferences()
+	}()
+	return out
+}
@@ -0,0 +1,45 @@
+package sydney
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSources = []SourceAttribute{
+	{Index: 2, Link: "https://example.com/france", Title: "France [facts]"},
+	{Index: 1, Link: "https://en.wikipedia.org/wiki/Paris", Title: "Paris - Wikipedia"},
+}
+
+func TestRewriteCitations(t *testing.T) {
+	assert.Equal(t, "Paris[[1]](https://en.wikipedia.org/wiki/Paris) is big (https://example.com/france)[^3^]."+
+		"\n\nReferences:\n1. [Paris - Wikipedia](https://en.wikipedia.org/wiki/Paris)"+
+		"\n2. [France \\[facts\\]](https://example.com/france)",
+		RewriteCitations("Paris[^1^] is big (^2^)[^3^].", testSources))
+	assert.Equal(t, "No citations.", RewriteCitations("No citations.", testSources))
+}
+func TestRewriteCitationsInStream(t *testing.T) {
+	ch := make(chan Message, 10)
+	for _, msg := range []Message{
+		{Type: MessageTypeSearchResult, SearchResult: testSources},
+		{Type: MessageTypeMessageText, Text: "Paris[^"},
+		{Type: MessageTypeMessageText, Text: "1^] is the capital"},
+		{Type: MessageTypeMessageText, Text: "[^2"},
+		{Type: MessageTypeMessageText, Text: "^]."},
+		{Type: MessageTypeSuggestedResponses, SuggestedResponses: []string{"More"}},
+	} {
+		ch <- msg
+	}
+	close(ch)
+	var text string
+	var types []string
+	for msg := range rewriteCitationsInStream(ch) {
+		types = append(types, msg.Type)
+		if msg.Type == MessageTypeMessageText {
+			text += msg.Text
+		}
+	}
+	assert.Equal(t, RewriteCitations("Paris[^1^] is the capital[^2^].", testSources), text)
+	assert.Equal(t, MessageTypeSearchResult, types[0])
+	assert.Equal(t, MessageTypeSuggestedResponses, types[len(types)-1])
+}
@@ -0,0 +1,97 @@
+package sydney
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	CodeOutputKindImage = "image"
+	CodeOutputKindFile  = "file"
+)
+
+// GeneratedCode is the code written and run by the code interpreter.
+type GeneratedCode struct {
+	Code     string `json:"code"`
+	Language string `json:"language"`
+}
+
+// CodeOutputFile is a chart or a file produced by the code interpreter.
+type CodeOutputFile struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Kind string `json:"kind"` // CodeOutputKindImage or CodeOutputKindFile
+}
+
+var fencedCodeReg = regexp.MustCompile("(?s)^\\s*```([\\w+#.-]*)[^\\n]*\\n(.*?)\\n?```\\s*$")
+
+// parseGeneratedCode reads a GeneratedCode message, whose text is the code, possibly fenced.
+// The code interpreter runs Python unless told otherwise.
+func parseGeneratedCode(message gjson.Result) GeneratedCode {
+	code := GeneratedCode{
+		Code:     message.Get("text").String(),
+		Language: strings.ToLower(message.Get("codeLanguage").String()),
+	}
+	if matches := fencedCodeReg.FindStringSubmatch(code.Code); matches != nil {
+		code.Code = matches[2]
+		if code.Language == "" {
+			code.Language = strings.ToLower(matches[1])
+		}
+	}
+	if code.Language == "" {
+		code.Language = "python"
+	}
+	return code
+}
+
+// parseCodeOutputFiles finds the images and file links in the adaptive cards of a code interpreter message.
+func parseCodeOutputFiles(message gjson.Result) []CodeOutputFile {
+	var files []CodeOutputFile
+	var walk func(v gjson.Result)
+	walk = func(v gjson.Result) {
+		if v.IsObject() {
+			link := v.Get("url").String()
+			switch v.Get("type").String() {
+			case "Image":
+				if link != "" {
+					files = append(files, CodeOutputFile{
+						Name: codeOutputFileName(v.Get("altText").String(), link),
+						URL:  link,
+						Kind: CodeOutputKindImage,
+					})
+				}
+			case "Action.OpenUrl":
+				if link != "" {
+					files = append(files, CodeOutputFile{
+						Name: codeOutputFileName(v.Get("title").String(), link),
+						URL:  link,
+						Kind: CodeOutputKindFile,
+					})
+				}
+			}
+		}
+		if v.IsObject() || v.IsArray() {
+			v.ForEach(func(_, value gjson.Result) bool {
+				walk(value)
+				return true
+			})
+		}
+	}
+	walk(message.Get("adaptiveCards"))
+	return files
+}
+func codeOutputFileName(title string, link string) string {
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+	if u, err := url.Parse(link); err == nil {
+		if name := path.Base(u.Path); name != "." && name != "/" {
+			return name
+		}
+	}
+	return "output"
+}
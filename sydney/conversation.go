@@ -1,6 +1,7 @@
 package sydney
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
@@ -10,22 +11,32 @@ import (
 	"time"
 )
 
-func (o *Sydney) createConversation() (CreateConversationResponse, error) {
+// createConversation creates a new conversation, retrying according to the retry policy.
+func (o *Sydney) createConversation(ctx context.Context) (CreateConversationResponse, error) {
+	var response CreateConversationResponse
+	err := o.retryPolicy.do(ctx, "creating conversation", func() error {
+		var err error
+		response, err = o.createConversationOnce(ctx)
+		return err
+	})
+	return response, err
+}
+func (o *Sydney) createConversationOnce(ctx context.Context) (CreateConversationResponse, error) {
 	var empty CreateConversationResponse
 	_, client, err := util.MakeHTTPClient(o.proxy, 10*time.Second)
 	if err != nil {
-		return empty, err
+		return empty, classify(ErrNetwork, err)
 	}
-	resp, err := client.R().SetHeader("Accept", "application/json").
+	resp, err := client.R().SetContext(ctx).SetHeader("Accept", "application/json").
 		SetHeader("Cookie", util.FormatCookieString(o.cookies)).Get(o.createConversationURL)
 	if err != nil {
-		return empty, err
+		return empty, classify(ErrNetwork, err)
 	}
 	bodyV := resp.Bytes()
 	if resp.GetStatusCode() != 200 {
 		slog.Error("Failed body", "v", string(bodyV))
-		return empty, errors.New("failed to create the conversation, code: " +
-			strconv.Itoa(resp.StatusCode) + "; please check your proxy settings and your account")
+		return empty, classify(classifyStatusCode(resp.StatusCode), errors.New("failed to create the conversation, code: "+
+			strconv.Itoa(resp.StatusCode)+"; please check your proxy settings and your account"))
 	}
 	var response CreateConversationResponse
 	err = json.Unmarshal(bodyV, &response)
@@ -33,7 +44,8 @@ func (o *Sydney) createConversation() (CreateConversationResponse, error) {
 		return empty, err
 	}
 	if response.Result.Value != "Success" {
-		return empty, errors.New("failed to create the conversation: message: " + response.Result.Message)
+		return empty, classify(classifyResult(response.Result.Value, response.Result.Message),
+			errors.New("failed to create the conversation: message: "+response.Result.Message))
 	}
 	if value := resp.Header.Get("X-Sydney-Encryptedconversationsignature"); value != "" {
 		response.SecAccessToken = value
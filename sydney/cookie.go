@@ -1,6 +1,7 @@
 package sydney
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"strconv"
@@ -8,31 +9,38 @@ import (
 	"time"
 )
 
+// GetUser returns the name of the Bing account the cookies belong to.
 func (o *Sydney) GetUser() (string, error) {
+	return o.GetUserContext(context.Background())
+}
+
+// GetUserContext is GetUser giving up once ctx is done.
+func (o *Sydney) GetUserContext(ctx context.Context) (string, error) {
 	_, client, err := util.MakeHTTPClient(o.proxy, 15*time.Second)
 	if err != nil {
 		return "", err
 	}
-	cookies, err := util.ReadCookiesFile()
-	if err != nil {
-		return "", err
-	}
-	if len(cookies) == 0 {
-		return "", errors.New("cookie file is empty")
+	if len(o.cookies) == 0 {
+		return "", classify(ErrCookieExpired, errors.New("cookies are empty"))
 	}
-	resp, err := client.R().
-		SetHeader("Cookie", util.FormatCookieString(cookies)).
+	resp, err := client.R().SetContext(ctx).
+		SetHeader("Cookie", util.FormatCookieString(o.cookies)).
 		Get("https://www.bing.com/search?q=Bing+AI&showconv=1")
 	if err != nil {
-		return "", err
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", classify(ErrNetwork, err)
 	}
 	if resp.GetStatusCode() != 200 {
-		return "", errors.New("http status code is not 200: " + strconv.Itoa(resp.GetStatusCode()))
+		return "", classify(classifyStatusCode(resp.GetStatusCode()),
+			errors.New("http status code is not 200: "+strconv.Itoa(resp.GetStatusCode())))
 	}
 	respText := resp.String()
 	arr := regexp.MustCompile(`data-clarity-mask="true" title="(.*?)"`).FindStringSubmatch(respText)
 	if len(arr) < 2 {
-		return "", errors.New("cannot identify current user, please check if cookie is expired")
+		return "", classify(ErrCookieExpired,
+			errors.New("cannot identify current user, please check if cookie is expired"))
 	}
 	return arr[1], nil
 }
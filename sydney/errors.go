@@ -0,0 +1,99 @@
+package sydney
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// classifiedError tags an error with one of the sentinel errors without changing its message,
+// so that errors.Is works for both of them.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// classify tags err with class. err is returned as is if either of them is nil.
+func classify(class error, err error) error {
+	if class == nil || err == nil {
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// errorKinds names the sentinel errors, so that the class of an error can be saved along with its message.
+var errorKinds = []struct {
+	name string
+	err  error
+}{
+	{"message_revoke", ErrMessageRevoke},
+	{"message_filtered", ErrMessageFiltered},
+	{"cookie_expired", ErrCookieExpired},
+	{"region_unsupported", ErrRegionUnsupported},
+	{"throttled", ErrThrottled},
+	{"captcha_required", ErrCaptchaRequired},
+	{"network", ErrNetwork},
+	{"context_too_long", ErrContextTooLong},
+	{"stream_stalled", ErrStreamStalled},
+	{"file_too_large", ErrFileTooLarge},
+}
+
+// errorKind returns the name of the sentinel error err is classified as, or "" if none.
+func errorKind(err error) string {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			return kind.name
+		}
+	}
+	return ""
+}
+
+// errorOfKind is the reverse of errorKind. It returns nil if the name is unknown.
+func errorOfKind(name string) error {
+	for _, kind := range errorKinds {
+		if kind.name == name {
+			return kind.err
+		}
+	}
+	return nil
+}
+
+// classifyResult maps the result value of Bing's responses to a sentinel error, or nil if unknown.
+func classifyResult(value string, message string) error {
+	switch value {
+	case "UnauthorizedRequest":
+		return ErrCookieExpired
+	case "Forbidden":
+		return ErrRegionUnsupported
+	case "Throttled":
+		return ErrThrottled
+	case "CaptchaChallenge":
+		return ErrCaptchaRequired
+	}
+	if strings.Contains(message, "CAPTCHA") {
+		return ErrCaptchaRequired
+	}
+	return nil
+}
+
+// classifyStatusCode maps the status code of a failed HTTP response to a sentinel error, or nil if unknown.
+func classifyStatusCode(code int) error {
+	switch {
+	case code == http.StatusUnauthorized:
+		return ErrCookieExpired
+	case code == http.StatusForbidden || code == http.StatusUnavailableForLegalReasons:
+		return ErrRegionUnsupported
+	case code == http.StatusTooManyRequests:
+		return ErrThrottled
+	case code >= 500:
+		return ErrNetwork
+	}
+	return nil
+}
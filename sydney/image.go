@@ -1,6 +1,7 @@
 package sydney
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/url"
@@ -11,15 +12,22 @@ import (
 )
 
 func (o *Sydney) GenerateImage(generativeImage GenerativeImage) (GenerateImageResult, error) {
+	return o.GenerateImageContext(context.Background(), generativeImage)
+}
+
+// GenerateImageContext creates the images and polls for them until ctx is done.
+func (o *Sydney) GenerateImageContext(ctx context.Context, generativeImage GenerativeImage) (GenerateImageResult, error) {
 	start := time.Now()
 	var empty GenerateImageResult
 	_, client, err := util.MakeHTTPClient(o.proxy, 15*time.Second)
 	if err != nil {
 		return empty, err
 	}
-	client.SetCommonHeader("Referer", "https://www.bing.com/search?q=Bing+AI&showconv=1&wlexpsignin=1").
-		SetCommonHeader("Cookie", util.FormatCookieString(o.cookies))
-	resp, err := client.R().Get(generativeImage.URL)
+	headers := map[string]string{
+		"Referer": "https://www.bing.com/search?q=Bing+AI&showconv=1&wlexpsignin=1",
+		"Cookie":  util.FormatCookieString(o.cookies),
+	}
+	resp, err := client.R().SetContext(ctx).SetHeaders(headers).Get(generativeImage.URL)
 	if err != nil {
 		return empty, err
 	}
@@ -32,9 +40,11 @@ func (o *Sydney) GenerateImage(generativeImage GenerativeImage) (GenerateImageRe
 	u := "https://www.bing.com/images/create/async/results/" + resultID +
 		"?q=" + url.QueryEscape(generativeImage.Text) + "&partner=sydney&showselective=1&IID=images.as"
 	slog.Info("Result URL", "v", u)
-	for i := 0; i < 15; i++ {
-		time.Sleep(3 * time.Second)
-		resp, err := client.R().Get(u)
+	for i := 0; i < o.pollAttempts; i++ {
+		if err := o.waitPoll(ctx); err != nil {
+			return empty, err
+		}
+		resp, err := client.R().SetContext(ctx).SetHeaders(headers).Get(u)
 		if err != nil {
 			return empty, err
 		}
@@ -0,0 +1,160 @@
+package sydney
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sydneyqt/util"
+)
+
+// Location is where the user is. Bing uses it for local search results, e.g. "weather today".
+type Location struct {
+	Name        string  `json:"name"` // e.g. "Los Angeles, California"
+	City        string  `json:"city"`
+	Admin1Name  string  `json:"admin1_name"`  // state or province
+	CountryName string  `json:"country_name"` // e.g. "United States"
+	CountryCode string  `json:"country_code"` // ISO 3166-1 alpha-2, sent as the region
+	Market      string  `json:"market"`       // Bing market, e.g. "en-US"; defaults to the locale
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	UtcOffset   int     `json:"utc_offset"`
+	PostCode    string  `json:"post_code,omitempty"`
+	Dma         int     `json:"dma,omitempty"` // designated market area, US only
+}
+
+// DefaultLocation is used when no location is given. Its market follows the locale.
+var DefaultLocation = Location{
+	Name:        "Los Angeles, California",
+	City:        "Los Angeles",
+	Admin1Name:  "California",
+	CountryName: "United States",
+	CountryCode: "US",
+	Latitude:    33.97570037841797,
+	Longitude:   -118.25640106201172,
+	UtcOffset:   -8,
+	PostCode:    "90060",
+	Dma:         803,
+}
+
+// LocationList is the built-in cities to choose from.
+var LocationList = []Location{
+	DefaultLocation,
+	{Name: "New York, New York", City: "New York", Admin1Name: "New York", CountryName: "United States",
+		CountryCode: "US", Market: "en-US", Latitude: 40.712776, Longitude: -74.005974, UtcOffset: -5, Dma: 501},
+	{Name: "Toronto, Ontario", City: "Toronto", Admin1Name: "Ontario", CountryName: "Canada",
+		CountryCode: "CA", Market: "en-CA", Latitude: 43.653225, Longitude: -79.383186, UtcOffset: -5},
+	{Name: "São Paulo, São Paulo", City: "São Paulo", Admin1Name: "São Paulo", CountryName: "Brazil",
+		CountryCode: "BR", Market: "pt-BR", Latitude: -23.550520, Longitude: -46.633308, UtcOffset: -3},
+	{Name: "London, England", City: "London", Admin1Name: "England", CountryName: "United Kingdom",
+		CountryCode: "GB", Market: "en-GB", Latitude: 51.507351, Longitude: -0.127758, UtcOffset: 0},
+	{Name: "Paris, Île-de-France", City: "Paris", Admin1Name: "Île-de-France", CountryName: "France",
+		CountryCode: "FR", Market: "fr-FR", Latitude: 48.856613, Longitude: 2.352222, UtcOffset: 1},
+	{Name: "Berlin, Berlin", City: "Berlin", Admin1Name: "Berlin", CountryName: "Germany",
+		CountryCode: "DE", Market: "de-DE", Latitude: 52.520008, Longitude: 13.404954, UtcOffset: 1},
+	{Name: "Madrid, Community of Madrid", City: "Madrid", Admin1Name: "Community of Madrid", CountryName: "Spain",
+		CountryCode: "ES", Market: "es-ES", Latitude: 40.416775, Longitude: -3.703790, UtcOffset: 1},
+	{Name: "Moscow, Moscow", City: "Moscow", Admin1Name: "Moscow", CountryName: "Russia",
+		CountryCode: "RU", Market: "ru-RU", Latitude: 55.755825, Longitude: 37.617298, UtcOffset: 3},
+	{Name: "Mumbai, Maharashtra", City: "Mumbai", Admin1Name: "Maharashtra", CountryName: "India",
+		CountryCode: "IN", Market: "en-IN", Latitude: 19.075983, Longitude: 72.877655, UtcOffset: 5},
+	{Name: "Singapore, Singapore", City: "Singapore", Admin1Name: "Singapore", CountryName: "Singapore",
+		CountryCode: "SG", Market: "en-SG", Latitude: 1.352083, Longitude: 103.819839, UtcOffset: 8},
+	{Name: "Jakarta, Jakarta", City: "Jakarta", Admin1Name: "Jakarta", CountryName: "Indonesia",
+		CountryCode: "ID", Market: "id-ID", Latitude: -6.208763, Longitude: 106.845599, UtcOffset: 7},
+	{Name: "Hong Kong, Hong Kong", City: "Hong Kong", Admin1Name: "Hong Kong", CountryName: "Hong Kong SAR",
+		CountryCode: "HK", Market: "zh-HK", Latitude: 22.319304, Longitude: 114.169361, UtcOffset: 8},
+	{Name: "Taipei, Taiwan", City: "Taipei", Admin1Name: "Taiwan", CountryName: "Taiwan",
+		CountryCode: "TW", Market: "zh-TW", Latitude: 25.032969, Longitude: 121.565418, UtcOffset: 8},
+	{Name: "Tokyo, Tokyo", City: "Tokyo", Admin1Name: "Tokyo", CountryName: "Japan",
+		CountryCode: "JP", Market: "ja-JP", Latitude: 35.676192, Longitude: 139.650311, UtcOffset: 9},
+	{Name: "Seoul, Seoul", City: "Seoul", Admin1Name: "Seoul", CountryName: "South Korea",
+		CountryCode: "KR", Market: "ko-KR", Latitude: 37.566535, Longitude: 126.977969, UtcOffset: 9},
+	{Name: "Sydney, New South Wales", City: "Sydney", Admin1Name: "New South Wales", CountryName: "Australia",
+		CountryCode: "AU", Market: "en-AU", Latitude: -33.868820, Longitude: 151.209296, UtcOffset: 10},
+}
+
+// forwardedIPPrefixes is a /24 block of a residential ISP of each country of LocationList, from which
+// the x-forwarded-for header is picked so that it agrees with the location.
+var forwardedIPPrefixes = map[string]string{
+	"US": "12.0.0.",    // AT&T
+	"CA": "142.112.0.", // Bell Canada
+	"BR": "177.0.0.",
+	"GB": "81.128.0.",  // BT
+	"FR": "90.0.0.",    // Orange
+	"DE": "79.192.0.",  // Deutsche Telekom
+	"ES": "88.0.0.",    // Telefónica
+	"RU": "95.24.0.",   // Beeline
+	"IN": "117.192.0.", // BSNL
+	"SG": "116.86.0.",  // StarHub
+	"ID": "36.64.0.",   // Telkom Indonesia
+	"HK": "112.118.0.", // Netvigator
+	"TW": "1.160.0.",   // HiNet
+	"JP": "126.0.0.",   // SoftBank
+	"KR": "175.192.0.", // KT
+	"AU": "1.120.0.",   // Telstra
+}
+
+// FindLocation finds a built-in location by its name or city, case-insensitively.
+func FindLocation(name string) (Location, bool) {
+	for _, location := range LocationList {
+		if strings.EqualFold(location.Name, name) || strings.EqualFold(location.City, name) {
+			return location, true
+		}
+	}
+	return Location{}, false
+}
+
+// IsZero reports whether no location is given, neither a city nor coordinates.
+func (l Location) IsZero() bool {
+	return l.Name == "" && l.Latitude == 0 && l.Longitude == 0
+}
+
+// region is the region sent along with messages, which defaults to US.
+func (l Location) region() string {
+	if l.CountryCode == "" {
+		return "US"
+	}
+	return strings.ToUpper(l.CountryCode)
+}
+
+// forwardedIP is a random IP address in the country of the location, or empty if the country is unknown,
+// in which case no x-forwarded-for header is sent rather than one contradicting the location.
+func (l Location) forwardedIP() string {
+	prefix, ok := forwardedIPPrefixes[l.region()]
+	if !ok {
+		return ""
+	}
+	return prefix + strconv.Itoa(util.RandIntInclusive(1, 254))
+}
+
+// market is the Bing market of the location, which defaults to the locale.
+func (l Location) market(locale string) string {
+	if l.Market == "" {
+		return locale
+	}
+	return l.Market
+}
+
+// location is the value of the location field of messages.
+func (l Location) location() string {
+	return fmt.Sprintf("lat:%.6f;long:%.6f;re=1000m;", l.Latitude, l.Longitude)
+}
+func (l Location) locationHint() LocationHint {
+	return LocationHint{
+		SourceType:               1,
+		RegionType:               2,
+		Center:                   LatLng{Latitude: l.Latitude, Longitude: l.Longitude},
+		Radius:                   24902,
+		Name:                     l.Name,
+		Accuracy:                 24902,
+		FDConfidence:             0.5,
+		CountryName:              l.CountryName,
+		CountryConfidence:        8,
+		Admin1Name:               l.Admin1Name,
+		PopulatedPlaceName:       l.City,
+		PopulatedPlaceConfidence: 5,
+		PostCodeName:             l.PostCode,
+		UtcOffset:                l.UtcOffset,
+		Dma:                      l.Dma,
+	}
+}
@@ -0,0 +1,66 @@
+package sydney
+
+import (
+	"context"
+	"sydneyqt/sydney/sydneytest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestFindLocation(t *testing.T) {
+	location, ok := FindLocation("tokyo")
+	assert.True(t, ok)
+	assert.Equal(t, "JP", location.CountryCode)
+	_, ok = FindLocation("Atlantis")
+	assert.False(t, ok)
+}
+func TestAskStreamLocation(t *testing.T) {
+	tokyo, _ := FindLocation("Tokyo")
+	for _, test := range []struct {
+		name     string
+		location Location
+		region   string
+		market   string
+		position string
+	}{
+		{"default", Location{}, "US", "zh-CN", "lat:33.975700;long:-118.256401;re=1000m;"},
+		{"built-in", tokyo, "JP", "ja-JP", "lat:35.676192;long:139.650311;re=1000m;"},
+		{"custom", Location{Latitude: 1.5, Longitude: -2.25}, "US", "zh-CN", "lat:1.500000;long:-2.250000;re=1000m;"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := sydneytest.NewServer()
+			defer server.Close()
+			server.Script(sydneytest.Finish())
+			syd := NewSydney(Options{
+				Locale:                "zh-CN",
+				Location:              test.location,
+				WssDomain:             server.WssDomain(),
+				CreateConversationURL: server.CreateConversationURL(),
+			})
+			_, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "weather"}))
+			assert.Nil(t, err)
+			requests := server.Requests()
+			if assert.Len(t, requests, 1) {
+				message := gjson.Get(requests[0], "arguments.0.message")
+				assert.Equal(t, "zh-CN", message.Get("locale").String())
+				assert.Equal(t, test.region, message.Get("region").String())
+				assert.Equal(t, test.market, message.Get("market").String())
+				assert.Equal(t, test.position, message.Get("location").String())
+				assert.Equal(t, syd.location.Latitude, message.Get("locationHints.0.Center.Latitude").Float())
+			}
+		})
+	}
+}
+func TestLocationForwardedIP(t *testing.T) {
+	tokyo, _ := FindLocation("Tokyo")
+	assert.Regexp(t, `^126\.0\.0\.\d+$`, tokyo.forwardedIP())
+	assert.Regexp(t, `^12\.0\.0\.\d+$`, DefaultLocation.forwardedIP())
+	assert.Empty(t, Location{Name: "Reykjavík", CountryCode: "IS"}.forwardedIP())
+	for _, location := range LocationList {
+		assert.NotEmpty(t, location.forwardedIP(), location.Name)
+	}
+	syd := NewSydney(Options{Location: Location{Name: "Reykjavík", CountryCode: "IS"}})
+	assert.NotContains(t, syd.headers(), "x-forwarded-for")
+}
@@ -1,6 +1,7 @@
 package sydney
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -30,17 +31,24 @@ type GenerateMusicRealResponse struct {
 }
 
 func (o *Sydney) GenerateMusic(generativeMusic GenerativeMusic) (GenerateMusicResult, error) {
+	return o.GenerateMusicContext(context.Background(), generativeMusic)
+}
+
+// GenerateMusicContext creates the music and polls for it until ctx is done.
+func (o *Sydney) GenerateMusicContext(ctx context.Context, generativeMusic GenerativeMusic) (GenerateMusicResult, error) {
 	start := time.Now()
 	var empty GenerateMusicResult
 	_, client, err := util.MakeHTTPClient(o.proxy, 15*time.Second)
 	if err != nil {
 		return empty, err
 	}
-	client.SetCommonHeader("Referer", "https://www.bing.com/search?q=Bing+AI&showconv=1&wlexpsignin=1").
-		SetCommonHeader("Cookie", util.FormatCookieString(o.cookies))
+	headers := map[string]string{
+		"Referer": "https://www.bing.com/search?q=Bing+AI&showconv=1&wlexpsignin=1",
+		"Cookie":  util.FormatCookieString(o.cookies),
+	}
 	u0 := "https://www.bing.com/videos/music?vdpp=suno&kseed=8000&SFX=3&q=&" +
 		"iframeid=" + generativeMusic.IFrameID + "&requestid=" + generativeMusic.RequestID
-	resp, err := client.R().Get(u0)
+	resp, err := client.R().SetContext(ctx).SetHeaders(headers).Get(u0)
 	if err != nil {
 		return empty, err
 	}
@@ -56,9 +64,11 @@ func (o *Sydney) GenerateMusic(generativeMusic GenerativeMusic) (GenerateMusicRe
 		"requestid=" + generativeMusic.RequestID + "&" +
 		"ig=" + hex.NewUpperHex(32) + "&iid=vsn&sfx=1"
 	slog.Info("Result URL", "v", u1)
-	for i := 0; i < 15; i++ {
-		time.Sleep(3 * time.Second)
-		resp, err = client.R().SetHeader("Referer", u0).Get(u1)
+	for i := 0; i < o.pollAttempts; i++ {
+		if err := o.waitPoll(ctx); err != nil {
+			return empty, err
+		}
+		resp, err = client.R().SetContext(ctx).SetHeaders(headers).SetHeader("Referer", u0).Get(u1)
 		if err != nil {
 			return empty, err
 		}
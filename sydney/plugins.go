@@ -1,18 +1,83 @@
 package sydney
 
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
 type Plugin struct {
-	Name        string
-	OptionsSets []string
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	OptionsSets []string `json:"options_sets"`
 	ArgumentPlugin
 }
 
-var PluginList = []Plugin{
-	{
-		Name:        "Suno",
-		OptionsSets: []string{"014CB21D"},
-		ArgumentPlugin: ArgumentPlugin{
-			Id:       "c310c353-b9f0-4d76-ab0d-1dd5e979cf68",
-			Category: 1,
+var (
+	pluginsMu  sync.RWMutex
+	PluginList = []Plugin{
+		{
+			Name:        "Suno",
+			Description: "Music creator. Generating audios, videos and cover images for music.",
+			OptionsSets: []string{"014CB21D"},
+			ArgumentPlugin: ArgumentPlugin{
+				Id:       "c310c353-b9f0-4d76-ab0d-1dd5e979cf68",
+				Category: 1,
+			},
 		},
-	},
+	}
+)
+
+// Plugins returns the available plugins, both built-in and loaded by LoadPluginFile.
+func Plugins() []Plugin {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	return slices.Clone(PluginList)
+}
+func findPlugin(name string) (Plugin, bool) {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	i := slices.IndexFunc(PluginList, func(item Plugin) bool {
+		return item.Name == name
+	})
+	if i == -1 {
+		return Plugin{}, false
+	}
+	return PluginList[i], true
+}
+
+// LoadPluginFile reads plugin definitions from a JSON array in the file and merges them into PluginList.
+// A plugin with the name of an existing one replaces it. A missing file is not an error.
+func LoadPluginFile(path string) error {
+	plugins, err := readDefinitionFile(path, pluginName, validatePlugin)
+	if err != nil {
+		return err
+	}
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	PluginList = mergeDefinitions(PluginList, plugins, pluginName)
+	if len(plugins) != 0 {
+		slog.Info("Loaded plugins", "path", path, "count", len(plugins))
+	}
+	return nil
+}
+func pluginName(plugin Plugin) string {
+	return plugin.Name
+}
+func validatePlugin(plugin Plugin) error {
+	if strings.TrimSpace(plugin.Name) == "" {
+		return errors.New("name is empty")
+	}
+	if strings.TrimSpace(plugin.Id) == "" {
+		return errors.New("id is empty: " + plugin.Name)
+	}
+	if plugin.Category < 0 {
+		return errors.New("category is negative: " + plugin.Name)
+	}
+	if slices.Contains(plugin.OptionsSets, "") {
+		return errors.New("options_sets contains an empty item: " + plugin.Name)
+	}
+	return nil
 }
@@ -0,0 +1,41 @@
+package sydney
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPluginFile(t *testing.T) {
+	builtin := PluginList
+	t.Cleanup(func() { PluginList = builtin })
+	PluginList = append([]Plugin(nil), builtin...)
+
+	path := filepath.Join(t.TempDir(), "plugins.json")
+	assert.Nil(t, LoadPluginFile(path)) // missing file
+	assert.Nil(t, os.WriteFile(path, []byte(`[
+		{"name": "Instacart", "options_sets": ["edgf"], "id": "46664d33-1591-4ce8-b3fb-ba1022b66c11", "category": 1},
+		{"name": "Suno", "description": "Replaced", "options_sets": ["014CB21D"], "id": "c310c353-b9f0-4d76-ab0d-1dd5e979cf68", "category": 1}
+	]`), 0644))
+	assert.Nil(t, LoadPluginFile(path))
+	plugins := Plugins()
+	if assert.Len(t, plugins, len(builtin)+1) {
+		assert.Equal(t, "Replaced", plugins[0].Description)
+		assert.Equal(t, "46664d33-1591-4ce8-b3fb-ba1022b66c11", plugins[len(plugins)-1].Id)
+	}
+	syd := NewSydney(Options{Plugins: []string{"Instacart"}})
+	assert.Contains(t, syd.optionsSet, "edgf")
+
+	for _, content := range []string{
+		`[{"name": "", "id": "x"}]`,
+		`[{"name": "NoID"}]`,
+		`[{"name": "Dup", "id": "x"}, {"name": "Dup", "id": "y"}]`,
+		`{"name": "NotArray"}`,
+	} {
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		assert.NotNil(t, LoadPluginFile(path), content)
+	}
+	assert.Len(t, Plugins(), len(builtin)+1)
+}
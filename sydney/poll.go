@@ -0,0 +1,23 @@
+package sydney
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DefaultPollInterval = 3 * time.Second
+	DefaultPollAttempts = 15
+)
+
+// waitPoll waits for the next poll of a long-running creation, returning the error of ctx if it is done first.
+func (o *Sydney) waitPoll(ctx context.Context) error {
+	timer := time.NewTimer(o.pollInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
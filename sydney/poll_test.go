@@ -0,0 +1,34 @@
+package sydney
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitPoll(t *testing.T) {
+	syd := NewSydney(Options{PollInterval: time.Millisecond})
+	assert.Equal(t, DefaultPollAttempts, syd.pollAttempts)
+	assert.Nil(t, syd.waitPoll(context.Background()))
+	syd = NewSydney(Options{PollInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	assert.ErrorIs(t, syd.waitPoll(ctx), context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
+func TestGenerateContextCanceled(t *testing.T) {
+	syd := NewSydney(Options{Cookies: map[string]string{"_U": "fake"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := syd.GenerateImageContext(ctx, GenerativeImage{URL: "https://www.bing.com/images/create"})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = syd.GenerateMusicContext(ctx, GenerativeMusic{})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = syd.UploadImageContext(ctx, []byte{})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = syd.GetUserContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
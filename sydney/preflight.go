@@ -0,0 +1,185 @@
+package sydney
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sydneyqt/util"
+
+	"github.com/samber/lo"
+)
+
+// DefaultUploadMaxSize is the default limit of a file to upload, after conversion.
+const DefaultUploadMaxSize = 10 << 20
+
+// preparedUpload is a file that passed the preflight and can be uploaded to Bing as is.
+type preparedUpload struct {
+	Path        string // of the original file
+	FileName    string
+	Data        []byte
+	ContentType string
+}
+
+type uploadConverter struct {
+	reader func(maxSize int) util.DocumentReader // limiting what is read from the file to maxSize
+	ext    string                                // of the converted file
+}
+
+// uploadConverters convert the common formats Bing does not handle well into ones it does, by file kind.
+var uploadConverters = map[string]uploadConverter{
+	"odt": {func(maxSize int) util.DocumentReader {
+		return util.OdtDocumentReader{MaxEntrySize: maxSize}
+	}, "txt"},
+	"epub": {func(maxSize int) util.DocumentReader {
+		return util.EpubDocumentReader{MaxEntrySize: maxSize}
+	}, "txt"},
+	"html": {func(maxSize int) util.DocumentReader {
+		return util.HTMLDocumentReader{}
+	}, "txt"},
+	"xlsx": {func(maxSize int) util.DocumentReader {
+		return util.XlsxDocumentReader{MaxEntrySize: maxSize}
+	}, "csv"},
+}
+
+var uploadContentTypes = map[string]string{
+	"pdf":  "application/pdf",
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"wav":  "audio/wav",
+}
+
+// UploadFileExtensions returns the extensions of the files that can be attached by AskStreamOptions,
+// including those converted before uploading.
+func UploadFileExtensions() []string {
+	return lo.Uniq(append(append([]string{}, BingAllowedFileExtensions...), "odt", "epub", "htm", "xhtml"))
+}
+
+// prepareUploads runs the preflight of all the files, so that nothing is uploaded if any of them fails.
+func (o *Sydney) prepareUploads(uploadFilePaths []string) ([]preparedUpload, error) {
+	var uploads []preparedUpload
+	for _, uploadFilePath := range uploadFilePaths {
+		upload, err := o.prepareUpload(uploadFilePath)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// prepareUpload sniffs the real type of the file, converts it if Bing does not accept it,
+// and checks the size of the result. Files over the limit are rejected before being read.
+func (o *Sydney) prepareUpload(uploadFilePath string) (preparedUpload, error) {
+	fileName := filepath.Base(uploadFilePath)
+	info, err := os.Stat(uploadFilePath)
+	if err != nil {
+		return preparedUpload{}, err
+	}
+	if info.Size() > int64(o.uploadMaxSize) {
+		return preparedUpload{}, fmt.Errorf("%w: %s is %s, more than the limit %s", ErrFileTooLarge,
+			fileName, formatFileSize(int(info.Size())), formatFileSize(o.uploadMaxSize))
+	}
+	data, err := os.ReadFile(uploadFilePath)
+	if err != nil {
+		return preparedUpload{}, err
+	}
+	ext := strings.TrimPrefix(filepath.Ext(fileName), ".")
+	baseName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	allowed := lo.ContainsBy(BingAllowedFileExtensions, func(item string) bool {
+		return strings.EqualFold(item, ext)
+	})
+	kind := sniffFileKind(data, ext)
+	if converter, ok := uploadConverters[kind]; ok {
+		text, err := converter.reader(o.uploadMaxSize).Read(uploadFilePath)
+		if err != nil {
+			return preparedUpload{}, fmt.Errorf("cannot convert %s: %w", fileName, err)
+		}
+		data = []byte(text)
+		fileName = baseName + "." + converter.ext
+		slog.Info("Converted file to upload", "path", uploadFilePath, "kind", kind, "name", fileName)
+	} else if _, ok := uploadContentTypes[kind]; ok && !strings.EqualFold(ext, kind) {
+		fileName = baseName + "." + kind // named after the real type, so that Bing parses it right
+	} else if !allowed {
+		if kind != "text" {
+			return preparedUpload{}, fmt.Errorf("file type .%s of %s is not supported", ext, fileName)
+		}
+		fileName += ".txt"
+	}
+	if len(data) > o.uploadMaxSize {
+		return preparedUpload{}, fmt.Errorf("%w: %s is %s, more than the limit %s", ErrFileTooLarge,
+			fileName, formatFileSize(len(data)), formatFileSize(o.uploadMaxSize))
+	}
+	finalExt := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	return preparedUpload{
+		Path:        uploadFilePath,
+		FileName:    fileName,
+		Data:        data,
+		ContentType: lo.ValueOr(uploadContentTypes, finalExt, http.DetectContentType(data)),
+	}, nil
+}
+
+// sniffFileKind tells the real type of a file by its content: pdf, docx, xlsx, pptx, odt, epub, zip,
+// html, text or binary. For text files, the extension takes precedence over the content.
+func sniffFileKind(data []byte, ext string) string {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "pdf"
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return sniffZipKind(data)
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "text/") {
+		return "binary"
+	}
+	if lo.Contains([]string{"html", "htm", "xhtml"}, strings.ToLower(ext)) ||
+		!lo.Contains(BingAllowedFileExtensions, ext) && strings.HasPrefix(contentType, "text/html") {
+		return "html"
+	}
+	return "text"
+}
+func sniffZipKind(data []byte) string {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "binary"
+	}
+	names := lo.Map(reader.File, func(item *zip.File, index int) string {
+		return item.Name
+	})
+	if lo.Contains(names, "mimetype") {
+		f, err := reader.Open("mimetype")
+		if err == nil {
+			defer f.Close()
+			var buf bytes.Buffer
+			_, _ = buf.ReadFrom(f)
+			switch strings.TrimSpace(buf.String()) {
+			case "application/vnd.oasis.opendocument.text":
+				return "odt"
+			case "application/epub+zip":
+				return "epub"
+			}
+		}
+	}
+	switch {
+	case lo.Contains(names, "word/document.xml"):
+		return "docx"
+	case lo.Contains(names, "xl/workbook.xml"):
+		return "xlsx"
+	case lo.Contains(names, "ppt/presentation.xml"):
+		return "pptx"
+	}
+	return "zip"
+}
+func formatFileSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,117 @@
+package sydney
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeZip(t *testing.T, path string, files [][2]string) {
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, file := range files {
+		fw, err := w.Create(file[0])
+		assert.Nil(t, err)
+		_, err = fw.Write([]byte(file[1]))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+}
+
+func TestPrepareUpload(t *testing.T) {
+	dir := t.TempDir()
+	syd := NewSydney(Options{UploadMaxSize: 80})
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	for _, item := range []struct {
+		path        string
+		fileName    string
+		contentType string
+		data        string
+	}{
+		{write("notes.md", "# Notes"), "notes.md", "text/plain; charset=utf-8", "# Notes"},
+		{write("paper.txt", "%PDF-1.7"), "paper.pdf", "application/pdf", "%PDF-1.7"},
+		{write("pom.xml", "<project></project>"), "pom.xml.txt", "text/plain; charset=utf-8", "<project></project>"},
+		{write("page.html", "<html><head><title>T</title></head><body><p>Tom &amp; Jerry</p></body></html>"),
+			"page.txt", "text/plain; charset=utf-8", "\nTom & Jerry"},
+	} {
+		upload, err := syd.prepareUpload(item.path)
+		assert.Nil(t, err)
+		assert.Equal(t, item.path, upload.Path)
+		assert.Equal(t, item.fileName, upload.FileName)
+		assert.Equal(t, item.contentType, upload.ContentType)
+		assert.Equal(t, item.data, string(upload.Data))
+	}
+	_, err := syd.prepareUpload(write("app.exe", "MZ\x90\x00\x03\x00\x00\x00"))
+	assert.ErrorContains(t, err, "not supported")
+	_, err = syd.prepareUpload(write("big.txt", string(make([]byte, 81))))
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	_, err = syd.prepareUploads([]string{filepath.Join(dir, "notes.md"), filepath.Join(dir, "big.txt")})
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+}
+func TestPrepareUploadConvert(t *testing.T) {
+	dir := t.TempDir()
+	syd := NewSydney(Options{})
+
+	odt := filepath.Join(dir, "letter.odt")
+	writeZip(t, odt, [][2]string{
+		{"mimetype", "application/vnd.oasis.opendocument.text"},
+		{"content.xml", `<office:document-content><office:body><office:text>` +
+			`<text:h>Title</text:h><text:p>Dear &lt;friend&gt;</text:p></office:text></office:body></office:document-content>`},
+	})
+	upload, err := syd.prepareUpload(odt)
+	assert.Nil(t, err)
+	assert.Equal(t, "letter.txt", upload.FileName)
+	assert.Equal(t, "\nTitle\nDear <friend>", string(upload.Data))
+
+	epub := filepath.Join(dir, "book.epub")
+	writeZip(t, epub, [][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`},
+		{"OEBPS/content.opf", `<package><manifest><item id="c1" href="c1.xhtml"/><item id="c2" href="c2.xhtml"/>` +
+			`</manifest><spine><itemref idref="c2"/><itemref idref="c1"/></spine></package>`},
+		{"OEBPS/c1.xhtml", `<html><body><p>Chapter 1</p></body></html>`},
+		{"OEBPS/c2.xhtml", `<html><body><p>Preface</p></body></html>`},
+	})
+	upload, err = syd.prepareUpload(epub)
+	assert.Nil(t, err)
+	assert.Equal(t, "book.txt", upload.FileName)
+	assert.Equal(t, "\nPreface\n\nChapter 1\n", string(upload.Data))
+
+	xlsx := filepath.Join(dir, "budget.xlsx")
+	writeZip(t, xlsx, [][2]string{
+		{"xl/workbook.xml", `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Q1" r:id="rId1"/><sheet name="Q2" r:id="rId2"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`},
+		{"xl/sharedStrings.xml", `<sst><si><t>Item</t></si><si><r><t>Co</t></r><r><t>st</t></r></si></sst>`},
+		{"xl/worksheets/sheet1.xml", `<worksheet><sheetData><row r="1"><c r="A1" t="s"><v>0</v></c>` +
+			`<c r="C1" t="s"><v>1</v></c></row><row r="2"><c r="A2" t="inlineStr"><is><t>Rent, office</t></is></c>` +
+			`<c r="C2"><v>1200</v></c></row></sheetData></worksheet>`},
+		{"xl/worksheets/sheet2.xml", `<worksheet><sheetData><row r="1"><c r="B1"><v>7</v></c></row></sheetData></worksheet>`},
+	})
+	upload, err = syd.prepareUpload(xlsx)
+	assert.Nil(t, err)
+	assert.Equal(t, "budget.csv", upload.FileName)
+	assert.Equal(t, "Item,,Cost\n\"Rent, office\",,1200\n\nQ2\n,7\n", string(upload.Data))
+}
+func TestPrepareUploadZipBomb(t *testing.T) {
+	dir := t.TempDir()
+	syd := NewSydney(Options{UploadMaxSize: 1024})
+	odt := filepath.Join(dir, "bomb.odt")
+	writeZip(t, odt, [][2]string{
+		{"mimetype", "application/vnd.oasis.opendocument.text"},
+		{"content.xml", strings.Repeat("<text:p>a</text:p>", 1024)},
+	})
+	_, err := syd.prepareUpload(odt)
+	assert.ErrorContains(t, err, "content.xml is larger than 1024 bytes once decompressed")
+}
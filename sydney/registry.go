@@ -0,0 +1,51 @@
+package sydney
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// readDefinitionFile reads a JSON array of definitions, e.g. plugins, and validates each of them.
+// Names must be unique within the file. A missing file results in no definitions.
+func readDefinitionFile[T any](path string, name func(T) string, validate func(T) error) ([]T, error) {
+	v, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var definitions []T
+	if err := json.Unmarshal(v, &definitions); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	names := map[string]bool{}
+	for i, definition := range definitions {
+		if err := validate(definition); err != nil {
+			return nil, fmt.Errorf("invalid item #%d in %s: %w", i+1, path, err)
+		}
+		if names[name(definition)] {
+			return nil, fmt.Errorf("duplicate item in %s: %s", path, name(definition))
+		}
+		names[name(definition)] = true
+	}
+	return definitions, nil
+}
+
+// mergeDefinitions appends definitions to existing ones, replacing those with the same name.
+func mergeDefinitions[T any](existing []T, definitions []T, name func(T) string) []T {
+	for _, definition := range definitions {
+		i := slices.IndexFunc(existing, func(item T) bool {
+			return name(item) == name(definition)
+		})
+		if i == -1 {
+			existing = append(existing, definition)
+		} else {
+			existing[i] = definition
+		}
+	}
+	return existing
+}
@@ -0,0 +1,60 @@
+package sydney
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// RetryPolicy controls how conversation creation and the websocket dial are retried
+// after failing with a retryable error.
+type RetryPolicy struct {
+	MaxAttempts  int           // Including the first attempt. 1 disables retrying.
+	InitialDelay time.Duration // Delay before the second attempt.
+	MaxDelay     time.Duration // Upper bound of the delay. Optional.
+	Multiplier   float64       // Growth of the delay after each attempt. Defaults to 2.
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  3,
+	InitialDelay: time.Second,
+	MaxDelay:     8 * time.Second,
+	Multiplier:   2,
+}
+
+// IsRetryable reports whether the request failing with err is worth retrying later.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrThrottled)
+}
+
+// Delay returns the delay after the given failed attempt, starting from 1.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// do calls f until it succeeds, fails with a non-retryable error, the attempts run out or ctx is done.
+func (p RetryPolicy) do(ctx context.Context, name string, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !IsRetryable(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+		delay := p.Delay(attempt)
+		slog.Warn("Retrying "+name, "attempt", attempt, "delay", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
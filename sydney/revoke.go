@@ -0,0 +1,65 @@
+package sydney
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// continueAfterRevoke passes the messages of ch through. When Bing revokes the answer,
+// it keeps the partial answer, appends it to the context and asks options.RevokeReplyText
+// in a new conversation, so that the answer goes on in the same stream.
+func (o *Sydney) continueAfterRevoke(options AskStreamOptions, ch <-chan Message) <-chan Message {
+	out := make(chan Message)
+	go func() {
+		defer close(out)
+		for count := 1; ; count++ {
+			var answer strings.Builder
+			revoked := false
+			for msg := range ch {
+				if errors.Is(msg.Error, ErrMessageRevoke) && count <= options.RevokeReplyCount {
+					revoked = true
+					continue
+				}
+				if msg.Type == MessageTypeMessageText {
+					answer.WriteString(msg.Text)
+				}
+				out <- msg
+			}
+			if !revoked || options.StopCtx.Err() != nil {
+				return
+			}
+			slog.Info("Message revoked, continuing the answer", "count", count, "partial", answer.String())
+			options.WebpageContext = appendContext(options.WebpageContext, "user", options.Prompt)
+			options.WebpageContext = appendContext(options.WebpageContext, "assistant", answer.String())
+			options.Prompt = options.RevokeReplyText
+			if options.TokenBudget > 0 { // the context has grown by the revoked turn
+				options.WebpageContext, _ = trimContext(options)
+			}
+			// the image and the files stay attached, as the new conversation has not seen them; the upload
+			// cache, if any, saves uploading the files again
+			if options.Session != nil { // Bing has dropped the revoked answer from the conversation
+				options.Session.Reset()
+			}
+			var err error
+			ch, err = o.askStreamOnce(options)
+			if err != nil {
+				out <- Message{
+					Type:  MessageTypeError,
+					Text:  err.Error(),
+					Error: err,
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// appendContext appends a message to a chat context in the format of the WebPage context.
+func appendContext(context string, role string, message string) string {
+	if context != "" {
+		context = strings.TrimRight(context, "\n") + "\n\n"
+	}
+	return context + "[" + role + "](#message)\n" + message
+}
@@ -0,0 +1,87 @@
+package sydney
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Session keeps a Bing conversation alive across several turns, so that follow-up prompts
+// are sent with isStartOfSession=false instead of replaying the whole chat history.
+// It only holds the state of the conversation: each turn is asked by the Sydney passed the session in
+// AskStreamOptions.Session, and a new conversation is started if it has other cookies than the one that
+// created the current conversation. A session is meant to be used by one turn at a time.
+type Session struct {
+	mu           sync.Mutex
+	conversation CreateConversationResponse
+	owner        string // the _U cookie of the Sydney that created the conversation
+	turn         int
+	maxTurns     int
+}
+
+func NewSession() *Session {
+	return &Session{}
+}
+
+// Turn returns the number of finished turns in the current conversation.
+func (o *Session) Turn() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.turn
+}
+func (o *Session) ConversationID() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.conversation.ConversationId
+}
+
+// Reset drops the current conversation. The next turn will create a new one.
+func (o *Session) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.conversation = CreateConversationResponse{}
+	o.turn = 0
+	o.maxTurns = 0
+}
+
+// prepare returns the conversation to use for the next turn and its turn index,
+// creating a new conversation if there is none or if Bing's turn limit has been reached.
+func (o *Session) prepare(ctx context.Context, syd *Sydney) (CreateConversationResponse, int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conversation.ConversationId != "" && o.owner != syd.cookies["_U"] {
+		slog.Info("Session is asked with other cookies, starting a new conversation",
+			"conversation-id", o.conversation.ConversationId)
+		o.conversation = CreateConversationResponse{}
+	}
+	if o.conversation.ConversationId != "" && o.maxTurns != 0 && o.turn >= o.maxTurns {
+		slog.Info("Session reached the max number of turns, starting a new conversation",
+			"conversation-id", o.conversation.ConversationId, "turns", o.turn)
+		o.conversation = CreateConversationResponse{}
+	}
+	if o.conversation.ConversationId == "" {
+		conversation, err := syd.createConversation(ctx)
+		if err != nil {
+			return CreateConversationResponse{}, 0, err
+		}
+		o.conversation = conversation
+		o.owner = syd.cookies["_U"]
+		o.turn = 0
+		o.maxTurns = 0
+	}
+	return o.conversation, o.turn, nil
+}
+
+// finishTurn is called after Bing has accepted a turn of the conversation.
+func (o *Session) finishTurn(conversationID string, numUserMessages int, maxNumUserMessages int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conversation.ConversationId != conversationID {
+		return
+	}
+	o.turn++
+	if numUserMessages > o.turn {
+		o.turn = numUserMessages
+	}
+	o.maxTurns = maxNumUserMessages
+}
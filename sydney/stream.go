@@ -21,12 +21,37 @@ import (
 )
 
 func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
-	out := make(chan Message)
+	ch, err := o.askStreamOnce(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.RevokeReplyText != "" && options.RevokeReplyCount > 0 {
+		ch = o.continueAfterRevoke(options, ch)
+	}
+	if options.RewriteCitations {
+		ch = rewriteCitationsInStream(ch)
+	}
+	return ch, nil
+}
+
+// askStreamOnce is AskStream without continuing after message revokes.
+func (o *Sydney) askStreamOnce(options AskStreamOptions) (<-chan Message, error) {
 	options.messageID = uuid.New().String()
-	conversation, ch, err := o.AskStreamRaw(options)
+	conversation, ch, trim, err := o.askStreamRaw(&options)
 	if err != nil {
 		return nil, err
 	}
+	out := o.decodeStream(options, conversation, ch)
+	if trim != nil {
+		out = withContextTrim(trim, out)
+	}
+	return out, nil
+}
+
+// decodeStream turns raw ChatHub messages into Message.
+func (o *Sydney) decodeStream(options AskStreamOptions, conversation CreateConversationResponse,
+	ch <-chan RawMessage) <-chan Message {
+	out := make(chan Message)
 	go func(out chan Message, ch <-chan RawMessage) {
 		defer func() {
 			slog.Info("AskStream is closing out message channel")
@@ -40,20 +65,39 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 				})
 				v, _ := json.Marshal(arr)
 				out <- Message{
-					Type: MessageTypeSuggestedResponses,
-					Text: string(v),
+					Type:               MessageTypeSuggestedResponses,
+					Text:               string(v),
+					SuggestedResponses: arr,
 				}
 			}
 		}
+		sentCodeOutputs := map[string]bool{} // by url, as the same message is updated many times
+		sendCodeOutput := func(message gjson.Result) {
+			files := lo.Filter(parseCodeOutputFiles(message), func(file CodeOutputFile, index int) bool {
+				return !sentCodeOutputs[file.URL]
+			})
+			if len(files) == 0 {
+				return
+			}
+			for _, file := range files {
+				sentCodeOutputs[file.URL] = true
+			}
+			v, _ := json.Marshal(files)
+			out <- Message{
+				Type:       MessageTypeCodeOutput,
+				Text:       string(v),
+				CodeOutput: files,
+			}
+		}
 		var sourceAttributes []SourceAttribute
 		tmpLastDocLoadingMessage := "" // for removing duplicate doc loading messages
 		for msg := range ch {
 			if msg.Error != nil {
 				slog.Error("Ask stream message", "error", msg.Error)
-				if strings.Contains(msg.Error.Error(), "CAPTCHA") {
+				if errors.Is(msg.Error, ErrCaptchaRequired) && !options.replay {
 					if options.disableCaptchaBypass {
-						err0 := errors.New("infinite CAPTCHA detected; " +
-							"please resolve it manually on Bing's website or mobile client")
+						err0 := classify(ErrCaptchaRequired, errors.New("infinite CAPTCHA detected; "+
+							"please resolve it manually on Bing's website or mobile client"))
 						out <- Message{
 							Type:  MessageTypeError,
 							Text:  err0.Error(),
@@ -61,21 +105,18 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 						}
 						return
 					}
-					slog.Info("Start to resolve the captcha", "server", o.bypassServer)
+					slog.Info("Start to resolve the captcha", "solver", fmt.Sprintf("%T", o.captchaSolver))
 					out <- Message{
 						Type: MessageTypeResolvingCaptcha,
 						Text: "Please wait patiently while we are resolving the CAPTCHA...",
 					}
-					if o.bypassServer == "" {
-						err = o.ResolveCaptcha(options.StopCtx)
-					} else {
-						err = o.BypassCaptcha(options.StopCtx, conversation.ConversationId,
-							options.messageID)
-					}
+					err := o.solveCaptcha(options.StopCtx, conversation.ConversationId, options.messageID)
 					if err != nil {
 						if !errors.Is(err, context.Canceled) {
-							err = fmt.Errorf("cannot resolve CAPTCHA automatically; "+
-								"please resolve it manually on Bing's website or mobile client: %w", err)
+							if !errors.Is(err, ErrCaptchaRequired) {
+								err = fmt.Errorf("cannot resolve CAPTCHA automatically; "+
+									"please resolve it manually on Bing's website or mobile client: %w", err)
+							}
 							out <- Message{
 								Type:  MessageTypeError,
 								Text:  err.Error(),
@@ -87,7 +128,7 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 					newOptions := options
 					newOptions.disableCaptchaBypass = true
 					newOptions.messageID = ""
-					newCh, err := o.AskStream(newOptions)
+					newCh, err := o.askStreamOnce(newOptions)
 					if err != nil {
 						out <- Message{
 							Type:  MessageTypeError,
@@ -191,8 +232,9 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 							util.GracefulPanic(err)
 						}
 						out <- Message{
-							Type: MessageTypeGenerativeImage,
-							Text: string(v),
+							Type:            MessageTypeGenerativeImage,
+							Text:            string(v),
+							GenerativeImage: &generativeImage,
 						}
 					case "SUNO":
 						generativeMusic := GenerativeMusic{
@@ -205,8 +247,9 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 							util.GracefulPanic(err)
 						}
 						out <- Message{
-							Type: MessageTypeGenerativeMusic,
-							Text: string(v),
+							Type:            MessageTypeGenerativeMusic,
+							Text:            string(v),
+							GenerativeMusic: &generativeMusic,
 						}
 					default:
 						continue
@@ -214,6 +257,7 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 				case "Progress":
 					switch contentOrigin {
 					case "CodeInterpreter":
+						sendCodeOutput(message)
 						invocation := message.Get("invocation").String()
 						if invocation == "" {
 							continue
@@ -237,11 +281,17 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 							"triggered-by", options.Prompt, "response", message.Raw)
 					}
 				case "GeneratedCode":
+					generatedCode := parseGeneratedCode(message)
 					out <- Message{
-						Type: MessageTypeGeneratedCode,
-						Text: messageText,
+						Type:          MessageTypeGeneratedCode,
+						Text:          generatedCode.Code,
+						GeneratedCode: &generatedCode,
 					}
+					sendCodeOutput(message)
 				case "":
+					if contentOrigin == "CodeInterpreter" {
+						sendCodeOutput(message)
+					}
 					if data.Get("arguments.0.cursor").Exists() {
 						wrote = 0
 						// extract search result from text block
@@ -277,8 +327,9 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 							}
 							if len(resultArr) != 0 {
 								out <- Message{
-									Type: MessageTypeSearchResult,
-									Text: "[\n" + strings.Join(resultArr, ",\n") + "\n]",
+									Type:         MessageTypeSearchResult,
+									Text:         "[\n" + strings.Join(resultArr, ",\n") + "\n]",
+									SearchResult: resultSources,
 								}
 							}
 						}
@@ -320,47 +371,78 @@ func (o *Sydney) AskStream(options AskStreamOptions) (<-chan Message, error) {
 			}
 		}
 	}(out, ch)
-	return out, nil
+	return out
 }
 func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationResponse, <-chan RawMessage, error) {
-	slog.Info("AskStreamRaw called, creating conversation...")
-	conversation, err := o.createConversation()
+	conversation, ch, _, err := o.askStreamRaw(&options)
+	return conversation, ch, err
+}
+
+// askStreamRaw is AskStreamRaw. As the context is only sent at the first turn of a conversation, which is known
+// once the session has been prepared, it trims options.WebpageContext there and returns how it has been trimmed.
+func (o *Sydney) askStreamRaw(options *AskStreamOptions) (CreateConversationResponse, <-chan RawMessage,
+	*ContextTrim, error) {
+	uploads, err := o.prepareUploads(lo.Uniq(lo.Compact(append([]string{options.UploadFilePath},
+		options.UploadFilePaths...))))
+	if err != nil {
+		return CreateConversationResponse{}, nil, nil, err
+	}
+	var conversation CreateConversationResponse
+	turn := 0
+	if options.Session != nil {
+		slog.Info("AskStreamRaw called, preparing session conversation...")
+		conversation, turn, err = options.Session.prepare(options.StopCtx, o)
+	} else {
+		slog.Info("AskStreamRaw called, creating conversation...")
+		conversation, err = o.createConversation(options.StopCtx)
+	}
 	if err != nil {
-		return CreateConversationResponse{}, nil, err
+		return CreateConversationResponse{}, nil, nil, err
 	}
-	slog.Info("Conversation created", "conversation-id", conversation.ConversationId)
+	slog.Info("Conversation created", "conversation-id", conversation.ConversationId, "turn", turn)
 	select {
 	case <-options.StopCtx.Done():
-		return conversation, nil, options.StopCtx.Err()
+		return conversation, nil, nil, options.StopCtx.Err()
 	default:
 	}
-	previousMessages := []PreviousMessage{
-		{
+	var trim *ContextTrim
+	if turn == 0 && options.TokenBudget > 0 {
+		options.WebpageContext, trim = trimContext(*options)
+	}
+	var previousMessages []PreviousMessage
+	if turn == 0 { // follow-up turns are already known by Bing
+		previousMessages = append(previousMessages, PreviousMessage{
 			Author:      "user",
 			Description: options.WebpageContext,
 			ContextType: "WebPage",
 			MessageType: "Context",
-		},
+		})
 	}
-	var uploadFileResult UploadFileResult
-	if options.UploadFilePath != "" {
-		slog.Info("Invoke file upload", "path", options.UploadFilePath)
-		uploadFileResult, err = o.uploadFile(options.UploadFilePath, conversation)
+	var uploadFileResults []UploadFileResult
+	if len(uploads) != 0 {
+		slog.Info("Invoke file upload", "files", len(uploads))
+		uploadFileResults, err = o.uploadFiles(options.StopCtx, uploads, conversation, options.OnUploadProgress)
 		if err != nil {
-			return CreateConversationResponse{}, nil, err
+			return CreateConversationResponse{}, nil, nil, err
 		}
 		select {
 		case <-options.StopCtx.Done():
-			return conversation, nil, options.StopCtx.Err()
+			return conversation, nil, nil, options.StopCtx.Err()
 		default:
 		}
+		hiddenText, err := json.Marshal(lo.Map(uploadFileResults, func(item UploadFileResult, index int) UploadFileHiddenText {
+			return item.HiddenText
+		}))
+		if err != nil {
+			return CreateConversationResponse{}, nil, nil, err
+		}
 		previousMessages = append(previousMessages, PreviousMessage{
 			Author: "user",
 			Description: "User has uploaded one or more files with the following metadata in Json format. " +
 				"I will use them as the main source of context when I answer questions from user.",
 			ContextType: "ClientApp",
 			MessageType: "Context",
-			HiddenText:  uploadFileResult.FileHiddenText,
+			HiddenText:  string(hiddenText),
 		})
 	}
 	msgChan := make(chan RawMessage)
@@ -391,27 +473,36 @@ func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationRespo
 		for k, v := range o.headers() {
 			httpHeaders.Set(k, v)
 		}
-		ctx, cancel := util.CreateTimeoutContext(10 * time.Second)
-		defer cancel()
-		connRaw, resp, err := websocket.Dial(ctx,
-			o.wssURL+util.Ternary(conversation.SecAccessToken != "", "?sec_access_token="+
-				url.QueryEscape(conversation.SecAccessToken), ""),
-			&websocket.DialOptions{
-				HTTPClient: client,
-				HTTPHeader: httpHeaders,
-			})
+		var connRaw *websocket.Conn
+		err = o.retryPolicy.do(options.StopCtx, "websocket dial", func() error {
+			ctx, cancel := util.CreateTimeoutContext(10 * time.Second)
+			defer cancel()
+			conn, resp, err := websocket.Dial(ctx,
+				o.wssURL+util.Ternary(conversation.SecAccessToken != "", "?sec_access_token="+
+					url.QueryEscape(conversation.SecAccessToken), ""),
+				&websocket.DialOptions{
+					HTTPClient: client,
+					HTTPHeader: httpHeaders,
+				})
+			if err != nil {
+				if resp != nil && resp.StatusCode != 101 {
+					return classify(classifyStatusCode(resp.StatusCode), err)
+				}
+				return classify(ErrNetwork, err)
+			}
+			if resp.StatusCode != 101 {
+				conn.CloseNow()
+				return classify(ErrNetwork, errors.New("cannot establish a websocket connection"))
+			}
+			connRaw = conn
+			return nil
+		})
 		if err != nil {
 			msgChan <- RawMessage{
 				Error: err,
 			}
 			return
 		}
-		if resp.StatusCode != 101 {
-			msgChan <- RawMessage{
-				Error: errors.New("cannot establish a websocket connection"),
-			}
-			return
-		}
 		defer connRaw.CloseNow()
 		select {
 		case <-options.StopCtx.Done():
@@ -428,7 +519,15 @@ func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationRespo
 			}
 			return
 		}
-		conn.ReadWithTimeout()
+		_, err = conn.ReadWithTimeout(options.StopCtx, o.readTimeout)
+		if err != nil {
+			if options.StopCtx.Err() == nil {
+				msgChan <- RawMessage{
+					Error: err,
+				}
+			}
+			return
+		}
 		err = conn.WriteWithTimeout([]byte(`{"type": 6}`))
 		if err != nil {
 			msgChan <- RawMessage{
@@ -448,23 +547,22 @@ func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationRespo
 					Plugins:             o.plugins,
 					TraceId:             util.MustGenerateRandomHex(16),
 					RequestId:           messageID,
-					IsStartOfSession:    true,
+					IsStartOfSession:    turn == 0,
 					Message: ArgumentMessage{
-						Locale: o.locale,
-						Market: o.locale,
-						Region: "US",
-						Location: fmt.Sprintf("lat:%.6f;long:%.6f;re=1000m;",
-							o.locationHint.Center.Latitude,
-							o.locationHint.Center.Longitude),
+						Locale:   o.locale,
+						Market:   o.location.market(o.locale),
+						Region:   o.location.region(),
+						Location: o.location.location(),
 						LocationHints: []LocationHint{
-							o.locationHint,
+							o.location.locationHint(),
 						},
-						AttachedFilesInfos: lo.Ternary(uploadFileResult.Valid, []ArgumentAttachedFilesInfo{
-							{
-								FileName: uploadFileResult.Response.FileName,
-								FileType: uploadFileResult.RealFileType,
-							},
-						}, nil),
+						AttachedFilesInfos: lo.Ternary(len(uploadFileResults) == 0, nil, lo.Map(uploadFileResults,
+							func(item UploadFileResult, index int) ArgumentAttachedFilesInfo {
+								return ArgumentAttachedFilesInfo{
+									FileName: item.Response.FileName,
+									FileType: item.RealFileType,
+								}
+							})),
 						Author:      "user",
 						InputMethod: "Keyboard",
 						Text:        options.Prompt,
@@ -483,7 +581,7 @@ func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationRespo
 					GptId:            o.gptID,
 				},
 			},
-			InvocationId: "0",
+			InvocationId: strconv.Itoa(turn),
 			Target:       "chat",
 			Type:         4,
 		}
@@ -501,29 +599,21 @@ func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationRespo
 			}
 			return
 		}
+		keepaliveCtx, cancelKeepalive := context.WithCancel(options.StopCtx)
+		defer cancelKeepalive()
+		go conn.Keepalive(keepaliveCtx, o.keepaliveInterval)
 		for {
-			select {
-			case <-options.StopCtx.Done():
-				slog.Info("Exit askStream because of received signal from stopCtx")
-				return
-			default:
-			}
-			messages, err := conn.ReadWithTimeout()
+			messages, err := conn.ReadWithTimeout(options.StopCtx, o.idleTimeout)
 			if err != nil {
+				if options.StopCtx.Err() != nil {
+					slog.Info("Exit askStream because of received signal from stopCtx")
+					return
+				}
 				msgChan <- RawMessage{
 					Error: err,
 				}
 				return
 			}
-			if time.Now().Unix()%6 == 0 {
-				err = conn.WriteWithTimeout([]byte(`{"type": 6}`))
-				if err != nil {
-					msgChan <- RawMessage{
-						Error: err,
-					}
-					return
-				}
-			}
 			for _, msg := range messages {
 				if msg == "" {
 					continue
@@ -536,10 +626,11 @@ func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationRespo
 				}
 				result := gjson.Parse(msg)
 				if result.Get("type").Int() == 2 && result.Get("item.result.value").String() != "Success" {
+					value := result.Get("item.result.value").String()
+					message := result.Get("item.result.message").String()
 					msgChan <- RawMessage{
-						Error: errors.New("bing explicit error: value: " +
-							result.Get("item.result.value").String() + "; message: " +
-							result.Get("item.result.message").String()),
+						Error: classify(classifyResult(value, message),
+							errors.New("bing explicit error: value: "+value+"; message: "+message)),
 					}
 					return
 				}
@@ -547,11 +638,19 @@ func (o *Sydney) AskStreamRaw(options AskStreamOptions) (CreateConversationRespo
 					Data: msg,
 				}
 				if result.Get("type").Int() == 2 {
+					if options.Session != nil {
+						options.Session.finishTurn(conversation.ConversationId,
+							int(result.Get("item.throttling.numUserMessagesInConversation").Int()),
+							int(result.Get("item.throttling.maxNumUserMessagesInConversation").Int()))
+					}
 					// finish the conversation
 					return
 				}
 			}
 		}
 	}(msgChan)
-	return conversation, msgChan, nil
+	if o.transcriptDir != "" {
+		return conversation, o.recordTranscript(*options, conversation, msgChan), trim, nil
+	}
+	return conversation, msgChan, trim, nil
 }
@@ -0,0 +1,323 @@
+package sydney
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sydneyqt/sydney/sydneytest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func newFakeSydney(t *testing.T) (*Sydney, *sydneytest.Server) {
+	server := sydneytest.NewServer()
+	t.Cleanup(server.Close)
+	// tests write the files to upload to the working directory
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return NewSydney(Options{
+		Cookies:               map[string]string{"_U": "fake"},
+		WssDomain:             server.WssDomain(),
+		CreateConversationURL: server.CreateConversationURL(),
+		BypassServer:          server.BypassServer(),
+		RetryPolicy:           RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond},
+	}), server
+}
+func collectMessages(ch <-chan Message, err error) ([]Message, error) {
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	for msg := range ch {
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func TestAskStreamText(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.Script(
+		sydneytest.Message("InternalSearchQuery", "weather today"),
+		sydneytest.Text("Hello"),
+		sydneytest.Text("Hello, world"),
+		sydneytest.Finish("Thanks"),
+	)
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	assert.Equal(t, []Message{
+		{Type: MessageTypeSearchQuery, Text: "weather today"},
+		{Type: MessageTypeMessageText, Text: "Hello"},
+		{Type: MessageTypeMessageText, Text: ", world"},
+		{Type: MessageTypeSuggestedResponses, Text: `["Thanks"]`, SuggestedResponses: []string{"Thanks"}},
+	}, messages)
+}
+func TestAskStreamRevoke(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.Script(sydneytest.Text("Some answer"), sydneytest.Apology("Sorry"))
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.ErrorIs(t, messages[1].Error, ErrMessageRevoke)
+	}
+}
+func TestAskStreamFiltered(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.Script(sydneytest.Apology("Sorry"))
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 1) {
+		assert.ErrorIs(t, messages[0].Error, ErrMessageFiltered)
+	}
+}
+func TestAskStreamCaptcha(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	var updatedCookies map[string]string
+	syd.onCookiesUpdated = func(cookies map[string]string) {
+		updatedCookies = cookies
+	}
+	server.Script(sydneytest.Captcha())
+	server.Script(sydneytest.Text("Solved"), sydneytest.Finish())
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, MessageTypeResolvingCaptcha, messages[0].Type)
+		assert.Equal(t, Message{Type: MessageTypeMessageText, Text: "Solved"}, messages[1])
+	}
+	assert.Equal(t, "fake-cct", syd.cookies["cct"])
+	assert.Equal(t, "fake-cct", updatedCookies["cct"])
+}
+func TestAskStreamDisconnect(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.Script(sydneytest.Text("Partial"), sydneytest.Disconnect())
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, MessageTypeMessageText, messages[0].Type)
+		assert.Equal(t, MessageTypeError, messages[1].Type)
+	}
+}
+func TestAskStreamCreateConversationFailure(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.FailCreate(401)
+	_, err := syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"})
+	assert.ErrorIs(t, err, ErrCookieExpired)
+	assert.Equal(t, 0, server.Conversations())
+}
+func TestAskStreamCreateConversationRetry(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.FailCreateTimes(503, 2)
+	server.Script(sydneytest.Text("Hello"), sydneytest.Finish())
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, 1, server.Conversations())
+}
+func TestAskStreamCreateConversationRetryExhausted(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.FailCreateTimes(429, 3)
+	_, err := syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"})
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.Equal(t, 0, server.Conversations())
+}
+func TestAskStreamCreateConversationCanceled(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := syd.AskStream(AskStreamOptions{StopCtx: ctx, Prompt: "hi"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, server.Conversations())
+}
+func TestAskStreamClassifiedErrors(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	for value, expected := range map[string]error{
+		"Throttled":           ErrThrottled,
+		"UnauthorizedRequest": ErrCookieExpired,
+		"Forbidden":           ErrRegionUnsupported,
+	} {
+		server.Script(sydneytest.Error(value, "Failed"))
+		messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+		assert.Nil(t, err)
+		if assert.Len(t, messages, 1) {
+			assert.ErrorIs(t, messages[0].Error, expected)
+			assert.Equal(t, "bing explicit error: value: "+value+"; message: Failed", messages[0].Text)
+		}
+	}
+}
+func TestSessionFollowUp(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	session := NewSession()
+	for _, prompt := range []string{"first", "second"} {
+		server.Script(sydneytest.Text("Answer to "+prompt), sydneytest.Finish())
+		messages, err := collectMessages(syd.AskStream(AskStreamOptions{
+			StopCtx:        context.Background(),
+			Session:        session,
+			Prompt:         prompt,
+			WebpageContext: "[user](#message)\nprevious",
+		}))
+		assert.Nil(t, err)
+		assert.Len(t, messages, 1)
+	}
+	assert.Equal(t, 1, server.Conversations())
+	assert.Equal(t, 2, session.Turn())
+	requests := server.Requests()
+	if assert.Len(t, requests, 2) {
+		assert.True(t, gjson.Get(requests[0], "arguments.0.isStartOfSession").Bool())
+		assert.Len(t, gjson.Get(requests[0], "arguments.0.previousMessages").Array(), 1)
+		assert.False(t, gjson.Get(requests[1], "arguments.0.isStartOfSession").Bool())
+		assert.Empty(t, gjson.Get(requests[1], "arguments.0.previousMessages").Array())
+		assert.Equal(t, "1", gjson.Get(requests[1], "invocationId").String())
+	}
+
+	// the conversation belongs to the account that created it
+	other := NewSydney(Options{
+		Cookies:               map[string]string{"_U": "other"},
+		WssDomain:             server.WssDomain(),
+		CreateConversationURL: server.CreateConversationURL(),
+	})
+	server.Script(sydneytest.Text("Answer to third"), sydneytest.Finish())
+	_, err := collectMessages(other.AskStream(AskStreamOptions{
+		StopCtx: context.Background(),
+		Session: session,
+		Prompt:  "third",
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, server.Conversations())
+	assert.Equal(t, 1, session.Turn())
+}
+func TestAskStreamKeepalive(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	syd.keepaliveInterval = 10 * time.Millisecond
+	server.Script(sydneytest.Text("Thinking"), sydneytest.Text("Thinking done").After(100*time.Millisecond),
+		sydneytest.Finish())
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	assert.Len(t, messages, 2)
+	assert.GreaterOrEqual(t, server.Pings(), 3)
+}
+func TestAskStreamStalled(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	syd.idleTimeout = 50 * time.Millisecond
+	server.Script(sydneytest.Text("Partial"), sydneytest.Finish().After(time.Second))
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.ErrorIs(t, messages[1].Error, ErrStreamStalled)
+	}
+}
+func TestAskStreamStop(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.Script(sydneytest.Text("Partial"), sydneytest.Finish().After(time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := syd.AskStream(AskStreamOptions{StopCtx: ctx, Prompt: "hi"})
+	assert.Nil(t, err)
+	msg := <-ch
+	assert.Equal(t, "Partial", msg.Text)
+	start := time.Now()
+	cancel()
+	for msg = range ch {
+		assert.NotEqual(t, MessageTypeError, msg.Type)
+	}
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+func TestAskStreamCaptchaSolver(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	var request CaptchaRequest
+	syd.captchaSolver = CaptchaSolverFunc(func(ctx context.Context, r CaptchaRequest) (map[string]string, error) {
+		request = r
+		return map[string]string{"cct": "custom-cct"}, nil
+	})
+	server.Script(sydneytest.Captcha())
+	server.Script(sydneytest.Text("Solved"), sydneytest.Finish())
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "fake", request.Cookies["_U"])
+	assert.Equal(t, "Conversation-1", request.ConversationID)
+	assert.Equal(t, "custom-cct", syd.cookies["cct"])
+}
+func TestAskStreamCaptchaFail(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	syd.captchaSolver = newCaptchaSolver(Options{CaptchaSolverName: CaptchaSolverFail})
+	server.Script(sydneytest.Captcha())
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, MessageTypeResolvingCaptcha, messages[0].Type)
+		assert.ErrorIs(t, messages[1].Error, ErrCaptchaRequired)
+		assert.Equal(t, "automatic CAPTCHA solving is disabled", messages[1].Text)
+	}
+	assert.Equal(t, 1, server.Conversations())
+}
+func TestNewCaptchaSolver(t *testing.T) {
+	assert.IsType(t, BrowserCaptchaSolver{}, newCaptchaSolver(Options{}))
+	assert.Equal(t, BypassCaptchaSolver{Server: "http://bypass"}, newCaptchaSolver(Options{BypassServer: "http://bypass"}))
+	RegisterCaptchaSolver("test", func(options Options) CaptchaSolver {
+		return BypassCaptchaSolver{Server: options.Proxy}
+	})
+	assert.Contains(t, CaptchaSolverNames(), "test")
+	assert.Equal(t, BypassCaptchaSolver{Server: "http://proxy"},
+		newCaptchaSolver(Options{CaptchaSolverName: "test", Proxy: "http://proxy"}))
+	_, err := newCaptchaSolver(Options{CaptchaSolverName: "unknown"}).SolveCaptcha(context.Background(), CaptchaRequest{})
+	assert.NotNil(t, err)
+}
+func TestAskStreamUploadFiles(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	syd.uploadFileURL = server.UploadFileURL()
+	assert.Nil(t, os.WriteFile("spec.txt", []byte("spec"), 0644))
+	assert.Nil(t, os.WriteFile("data.csv", []byte("a,b"), 0644))
+	server.Script(sydneytest.Text("Compared"), sydneytest.Finish())
+	var mu sync.Mutex
+	var progress []UploadProgress
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{
+		StopCtx:         context.Background(),
+		Prompt:          "compare",
+		UploadFilePath:  "spec.txt",
+		UploadFilePaths: []string{"data.csv", "spec.txt"},
+		OnUploadProgress: func(p UploadProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		},
+	}))
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.ElementsMatch(t, []string{"spec.txt", "data.csv"}, server.Uploads())
+	assert.Len(t, progress, 4)
+	for _, p := range progress {
+		assert.Equal(t, 2, p.Total)
+		assert.Equal(t, []string{"spec.txt", "data.csv"}[p.Index], p.Path)
+	}
+	assert.Equal(t, 2, len(lo.Filter(progress, func(p UploadProgress, index int) bool {
+		return p.Status == UploadStatusUploaded
+	})))
+	requests := server.Requests()
+	if assert.Len(t, requests, 1) {
+		var hiddenText []UploadFileHiddenText
+		assert.Nil(t, json.Unmarshal([]byte(gjson.Get(requests[0],
+			`arguments.0.previousMessages.#(contextType=="ClientApp").hiddenText`).String()), &hiddenText))
+		assert.Equal(t, []string{"Doc-spec.txt", "Doc-data.csv"},
+			lo.Map(hiddenText, func(item UploadFileHiddenText, index int) string { return item.DocId }))
+		assert.Equal(t, `[{"fileName":"spec.txt","fileType":"text"},{"fileName":"data.csv","fileType":"text"}]`,
+			gjson.Get(requests[0], "arguments.0.message.attachedFilesInfos").Raw)
+	}
+}
+func TestAskStreamUploadFilesFailure(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	syd.uploadFileURL = server.UploadFileURL()
+	assert.Nil(t, os.WriteFile("spec.txt", []byte("spec"), 0644))
+	assert.Nil(t, os.WriteFile("fail.txt", []byte("fail"), 0644))
+	_, err := syd.AskStream(AskStreamOptions{
+		StopCtx:         context.Background(),
+		Prompt:          "compare",
+		UploadFilePaths: []string{"spec.txt", "fail.txt"},
+	})
+	assert.ErrorContains(t, err, "cannot upload fail.txt")
+	assert.Empty(t, server.Requests())
+}
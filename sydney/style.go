@@ -0,0 +1,134 @@
+package sydney
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ConversationStyle defines what Options.ConversationStyle of the same name is sent as,
+// including Copilot GPT personas selected by GptID.
+type ConversationStyle struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tone        string   `json:"tone"`                   // e.g. Creative
+	ClassicTone string   `json:"classic_tone,omitempty"` // Tone if Options.UseClassic is set. Empty if not supported.
+	OptionsSets []string `json:"options_sets"`           // In addition to the common ones.
+	GptID       string   `json:"gpt_id,omitempty"`       // Defaults to copilot.
+	GPT4Turbo   bool     `json:"gpt4_turbo"`             // Whether Options.GPT4Turbo applies.
+	Models      []string `json:"models,omitempty"`       // Prefixes of OpenAI model names mapped to this style.
+}
+
+// DefaultConversationStyle is used if Options.ConversationStyle is empty or unknown.
+const DefaultConversationStyle = "Creative"
+
+var (
+	conversationStylesMu  sync.RWMutex
+	ConversationStyleList = []ConversationStyle{
+		{
+			Name:        "Creative",
+			Description: "Original and imaginative.",
+			Tone:        "Creative",
+			ClassicTone: "CreativeClassic",
+			GPT4Turbo:   true,
+			Models:      []string{"gpt-4"},
+		},
+		{
+			Name:        "Balanced",
+			Description: "Informative and friendly.",
+			Tone:        "Balanced",
+			OptionsSets: []string{"galileo", "gldcl1p"},
+			GPT4Turbo:   true,
+			Models:      []string{"gpt-3.5-turbo"},
+		},
+		{
+			Name:        "Precise",
+			Description: "Concise and straightforward.",
+			Tone:        "Precise",
+			OptionsSets: []string{"h3precise"},
+			GPT4Turbo:   true,
+		},
+		{
+			Name:        "Designer",
+			Description: "Copilot GPT for creating images.",
+			Tone:        "Creative",
+			OptionsSets: []string{"ai_persona_designer_gpt"},
+			GptID:       "designer",
+			GPT4Turbo:   true,
+		},
+	}
+)
+
+// ConversationStyles returns the available conversation styles, both built-in and loaded by LoadConversationStyleFile.
+func ConversationStyles() []ConversationStyle {
+	conversationStylesMu.RLock()
+	defer conversationStylesMu.RUnlock()
+	return slices.Clone(ConversationStyleList)
+}
+func findConversationStyle(name string) (ConversationStyle, bool) {
+	conversationStylesMu.RLock()
+	defer conversationStylesMu.RUnlock()
+	i := slices.IndexFunc(ConversationStyleList, func(item ConversationStyle) bool {
+		return item.Name == name
+	})
+	if i == -1 {
+		return ConversationStyle{}, false
+	}
+	return ConversationStyleList[i], true
+}
+
+// ConversationStyleForModel returns the name of the conversation style an OpenAI model name is mapped to.
+// A model named after a style, case-insensitively, is mapped to it as well.
+func ConversationStyleForModel(model string) string {
+	conversationStylesMu.RLock()
+	defer conversationStylesMu.RUnlock()
+	model = strings.ToLower(model)
+	for _, style := range ConversationStyleList {
+		if strings.ToLower(style.Name) == model {
+			return style.Name
+		}
+	}
+	// the longest prefix wins, so that gpt-4 and gpt-4-turbo can be mapped differently
+	result, length := DefaultConversationStyle, 0
+	for _, style := range ConversationStyleList {
+		for _, prefix := range style.Models {
+			if strings.HasPrefix(model, strings.ToLower(prefix)) && len(prefix) > length {
+				result, length = style.Name, len(prefix)
+			}
+		}
+	}
+	return result
+}
+
+// LoadConversationStyleFile reads conversation styles from a JSON array in the file and merges them
+// into ConversationStyleList. A style with the name of an existing one replaces it. A missing file is not an error.
+func LoadConversationStyleFile(path string) error {
+	styles, err := readDefinitionFile(path, conversationStyleName, validateConversationStyle)
+	if err != nil {
+		return err
+	}
+	conversationStylesMu.Lock()
+	defer conversationStylesMu.Unlock()
+	ConversationStyleList = mergeDefinitions(ConversationStyleList, styles, conversationStyleName)
+	if len(styles) != 0 {
+		slog.Info("Loaded conversation styles", "path", path, "count", len(styles))
+	}
+	return nil
+}
+func conversationStyleName(style ConversationStyle) string {
+	return style.Name
+}
+func validateConversationStyle(style ConversationStyle) error {
+	if strings.TrimSpace(style.Name) == "" {
+		return errors.New("name is empty")
+	}
+	if strings.TrimSpace(style.Tone) == "" {
+		return errors.New("tone is empty: " + style.Name)
+	}
+	if slices.Contains(style.OptionsSets, "") {
+		return errors.New("options_sets contains an empty item: " + style.Name)
+	}
+	return nil
+}
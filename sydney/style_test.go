@@ -0,0 +1,58 @@
+package sydney
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSydneyConversationStyle(t *testing.T) {
+	for _, test := range []struct {
+		options     Options
+		tone        string
+		gptID       string
+		optionsSets []string
+	}{
+		{Options{}, "Creative", "copilot", nil},
+		{Options{ConversationStyle: "Creative", UseClassic: true}, "CreativeClassic", "copilot", nil},
+		{Options{ConversationStyle: "Balanced", GPT4Turbo: true}, "Balanced", "copilot", []string{"galileo", "gpt4tmncnp"}},
+		{Options{ConversationStyle: "Precise"}, "Precise", "copilot", []string{"h3precise"}},
+		{Options{ConversationStyle: "Designer"}, "Creative", "designer", []string{"ai_persona_designer_gpt"}},
+		{Options{ConversationStyle: "Unknown"}, "Creative", "copilot", nil},
+	} {
+		syd := NewSydney(test.options)
+		assert.Equal(t, test.tone, syd.conversationStyle)
+		assert.Equal(t, test.gptID, syd.gptID)
+		for _, item := range test.optionsSets {
+			assert.Contains(t, syd.optionsSet, item)
+		}
+	}
+}
+func TestConversationStyleForModel(t *testing.T) {
+	assert.Equal(t, "Balanced", ConversationStyleForModel("gpt-3.5-turbo-16k"))
+	assert.Equal(t, "Creative", ConversationStyleForModel("gpt-4-turbo"))
+	assert.Equal(t, "Creative", ConversationStyleForModel("some-model"))
+	assert.Equal(t, "Designer", ConversationStyleForModel("designer"))
+}
+func TestLoadConversationStyleFile(t *testing.T) {
+	builtin := ConversationStyleList
+	t.Cleanup(func() { ConversationStyleList = builtin })
+	ConversationStyleList = append([]ConversationStyle(nil), builtin...)
+
+	path := filepath.Join(t.TempDir(), "styles.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`[
+		{"name": "Travel", "tone": "Creative", "options_sets": ["travelplanner"], "gpt_id": "travel", "models": ["travel-"]}
+	]`), 0644))
+	assert.Nil(t, LoadConversationStyleFile(path))
+	assert.Len(t, ConversationStyles(), len(builtin)+1)
+	assert.Equal(t, "Travel", ConversationStyleForModel("travel-1"))
+	syd := NewSydney(Options{ConversationStyle: "Travel", GPT4Turbo: true})
+	assert.Equal(t, "travel", syd.gptID)
+	assert.Contains(t, syd.optionsSet, "travelplanner")
+	assert.NotContains(t, syd.optionsSet, "gpt4tmncnp")
+
+	assert.Nil(t, os.WriteFile(path, []byte(`[{"name": "NoTone"}]`), 0644))
+	assert.NotNil(t, LoadConversationStyleFile(path))
+}
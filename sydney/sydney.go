@@ -3,8 +3,9 @@ package sydney
 import (
 	"github.com/samber/lo"
 	"log/slog"
-	"strconv"
+	"strings"
 	"sydneyqt/util"
+	"time"
 
 	"github.com/google/uuid"
 	clone "github.com/huandu/go-clone/generic"
@@ -17,11 +18,22 @@ type Sydney struct {
 	locale                string
 	wssURL                string
 	createConversationURL string
-	bypassServer          string
+	uploadFileURL         string
+	uploadMaxSize         int
+	uploadCache           *UploadCache
+	captchaSolver         CaptchaSolver
+	onCookiesUpdated      func(cookies map[string]string)
+	transcriptDir         string
+	retryPolicy           RetryPolicy
+	keepaliveInterval     time.Duration
+	readTimeout           time.Duration
+	idleTimeout           time.Duration
+	pollInterval          time.Duration
+	pollAttempts          int
 
 	optionsSet          []string
 	sliceIDs            []string
-	locationHint        LocationHint
+	location            Location
 	allowedMessageTypes []string
 	headers             func() map[string]string
 	cookies             map[string]string
@@ -60,33 +72,22 @@ func NewSydney(options Options) *Sydney {
 		"ldsummary",   // our guess: long document summary
 		"ldqa",        // our guess: long document quality assurance
 	}
-	forwardedIP := "1.0.0." + strconv.Itoa(util.RandIntInclusive(1, 255))
 	cookies := util.Ternary(options.Cookies == nil, map[string]string{}, options.Cookies)
-	options.ConversationStyle = lo.Ternary(options.ConversationStyle == "",
-		"Creative", options.ConversationStyle)
-	gptID := "copilot"
-	switch options.ConversationStyle {
-	case "Balanced":
-		optionsSet = append(optionsSet, "galileo", "gldcl1p")
-	case "Precise":
-		optionsSet = append(optionsSet, "h3precise")
-	case "Creative":
-		if options.UseClassic {
-			options.ConversationStyle = "CreativeClassic"
-		}
-	case "Designer":
-		optionsSet = append(optionsSet, "ai_persona_designer_gpt")
-		options.ConversationStyle = "Creative"
-		gptID = "designer"
-	default:
+	style, ok := findConversationStyle(lo.Ternary(options.ConversationStyle == "",
+		DefaultConversationStyle, options.ConversationStyle))
+	if !ok {
 		slog.Warn("Conversation style not found", "param", options.ConversationStyle,
-			"fallback-to", "Creative")
-		options.ConversationStyle = "Creative"
+			"fallback-to", DefaultConversationStyle)
+		style, _ = findConversationStyle(DefaultConversationStyle)
 	}
+	optionsSet = append(optionsSet, style.OptionsSets...)
+	options.ConversationStyle = lo.Ternary(options.UseClassic && style.ClassicTone != "",
+		style.ClassicTone, style.Tone)
+	gptID := lo.Ternary(style.GptID == "", "copilot", style.GptID)
 	if options.NoSearch && len(options.Plugins) == 0 {
 		optionsSet = append(optionsSet, "nosearchall")
 	}
-	if options.GPT4Turbo && !options.UseClassic {
+	if options.GPT4Turbo && !options.UseClassic && style.GPT4Turbo {
 		optionsSet = append(optionsSet, "gpt4tmncnp")
 	}
 	if debugOptionSets := util.ReadDebugOptionSets(); len(debugOptionSets) != 0 {
@@ -94,9 +95,7 @@ func NewSydney(options Options) *Sydney {
 	}
 	var plugins []ArgumentPlugin
 	for _, pluginName := range options.Plugins {
-		plugin, ok := lo.Find(PluginList, func(item Plugin) bool {
-			return item.Name == pluginName
-		})
+		plugin, ok := findPlugin(pluginName)
 		if !ok {
 			slog.Warn("Plugin not found", "name", pluginName)
 			continue
@@ -105,38 +104,33 @@ func NewSydney(options Options) *Sydney {
 		plugins = append(plugins, plugin.ArgumentPlugin)
 	}
 	slog.Info("Final conversation options", "options", optionsSet, "tone", options.ConversationStyle)
+	location := lo.Ternary(options.Location.IsZero(), DefaultLocation, options.Location)
+	forwardedIP := location.forwardedIP()
 	return &Sydney{
 		debug:             options.Debug,
 		proxy:             options.Proxy,
 		conversationStyle: options.ConversationStyle,
 		locale:            util.Ternary(options.Locale == "", "en-US", options.Locale),
-		wssURL: util.Ternary(options.WssDomain == "", "wss://sydney.bing.com/sydney/ChatHub",
-			"wss://"+options.WssDomain+"/sydney/ChatHub"),
+		wssURL:            makeWssURL(options.WssDomain),
 		createConversationURL: util.Ternary(options.CreateConversationURL == "",
 			"https://edgeservices.bing.com/edgesvc/turing/conversation/create", options.CreateConversationURL),
-		bypassServer: options.BypassServer,
+		uploadFileURL:    "https://sydney.bing.com/sydney/UploadFile",
+		uploadMaxSize:    lo.Ternary(options.UploadMaxSize <= 0, DefaultUploadMaxSize, options.UploadMaxSize),
+		uploadCache:      options.UploadCache,
+		captchaSolver:    newCaptchaSolver(options),
+		onCookiesUpdated: options.OnCookiesUpdated,
+		transcriptDir:    options.TranscriptDir,
+		retryPolicy: lo.Ternary(options.RetryPolicy.MaxAttempts == 0,
+			DefaultRetryPolicy, options.RetryPolicy),
+		keepaliveInterval: lo.Ternary(options.KeepaliveInterval <= 0,
+			DefaultKeepaliveInterval, options.KeepaliveInterval),
+		readTimeout:  lo.Ternary(options.ReadTimeout <= 0, DefaultReadTimeout, options.ReadTimeout),
+		idleTimeout:  lo.Ternary(options.IdleTimeout <= 0, DefaultIdleTimeout, options.IdleTimeout),
+		pollInterval: lo.Ternary(options.PollInterval <= 0, DefaultPollInterval, options.PollInterval),
+		pollAttempts: lo.Ternary(options.PollAttempts <= 0, DefaultPollAttempts, options.PollAttempts),
 		optionsSet:   optionsSet,
 		sliceIDs:     []string{},
-		locationHint: LocationHint{
-			SourceType: 1,
-			RegionType: 2,
-			Center: LatLng{
-				Latitude:  33.97570037841797,
-				Longitude: -118.25640106201172,
-			},
-			Radius:                   24902,
-			Name:                     "Los Angeles, California",
-			Accuracy:                 24902,
-			FDConfidence:             0.5,
-			CountryName:              "United States",
-			CountryConfidence:        8,
-			Admin1Name:               "California",
-			PopulatedPlaceName:       "Los Angeles",
-			PopulatedPlaceConfidence: 5,
-			PostCodeName:             "90060",
-			UtcOffset:                -8,
-			Dma:                      803,
-		},
+		location:     location,
 		allowedMessageTypes: []string{
 			"ActionRequest",
 			"Chat",
@@ -150,7 +144,7 @@ func NewSydney(options Options) *Sydney {
 			"GeneratedCode",
 		},
 		headers: func() map[string]string {
-			return map[string]string{
+			headers := map[string]string{
 				"accept":                      "application/json",
 				"accept-language":             "en-US,en;q=0.9",
 				"content-type":                "application/json",
@@ -173,12 +167,27 @@ func NewSydney(options Options) *Sydney {
 				"user-agent":                  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/113.0.0.0 Safari/537.36 Edg/113.0.1774.50",
 				"Referer":                     "https://www.bing.com/search?q=Bing+AI&showconv=1",
 				"Referrer-Policy":             "origin-when-cross-origin",
-				"x-forwarded-for":             forwardedIP,
 				"Cookie":                      util.FormatCookieString(cookies),
 			}
+			if forwardedIP != "" {
+				headers["x-forwarded-for"] = forwardedIP
+			}
+			return headers
 		},
 		cookies: cookies,
 		gptID:   gptID,
 		plugins: plugins,
 	}
 }
+
+// makeWssURL builds the ChatHub url from a domain. A domain with a scheme
+// (e.g. ws://127.0.0.1:8080) is used as is, which is handy for local servers.
+func makeWssURL(wssDomain string) string {
+	if wssDomain == "" {
+		wssDomain = "sydney.bing.com"
+	}
+	if !strings.Contains(wssDomain, "://") {
+		wssDomain = "wss://" + wssDomain
+	}
+	return strings.TrimSuffix(wssDomain, "/") + "/sydney/ChatHub"
+}
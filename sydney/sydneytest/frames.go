@@ -0,0 +1,129 @@
+package sydneytest
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// numUserMessagesPlaceholder is replaced by the real number of user messages in the conversation.
+const numUserMessagesPlaceholder = "__NUM_USER_MESSAGES__"
+
+// Frame is a single ChatHub message sent by the server during a scripted turn.
+type Frame struct {
+	data       string
+	delay      time.Duration
+	disconnect bool
+}
+
+// After delays the frame by d.
+func (f Frame) After(d time.Duration) Frame {
+	f.delay = d
+	return f
+}
+
+// Raw sends data as is.
+func Raw(data string) Frame {
+	return Frame{data: data}
+}
+
+// Text sends a bot message update. Like Bing, text is the whole message written so far.
+func Text(text string) Frame {
+	return update(map[string]any{
+		"text":   text,
+		"author": "bot",
+	})
+}
+
+// Message sends a bot message with the given messageType, e.g. InternalSearchQuery.
+func Message(messageType string, text string) Frame {
+	return update(map[string]any{
+		"text":        text,
+		"author":      "bot",
+		"messageType": messageType,
+	})
+}
+
+// Apology sends a bot message with contentOrigin Apology, which sydney treats as a revoke.
+func Apology(text string) Frame {
+	return update(map[string]any{
+		"text":          text,
+		"author":        "bot",
+		"contentOrigin": "Apology",
+	})
+}
+
+// Update sends an arbitrary bot message.
+func Update(message map[string]any) Frame {
+	return update(message)
+}
+
+// Finish ends the turn successfully.
+func Finish(suggestedResponses ...string) Frame {
+	var suggestions []map[string]any
+	for _, text := range suggestedResponses {
+		suggestions = append(suggestions, map[string]any{"text": text})
+	}
+	message := map[string]any{"author": "bot"}
+	if len(suggestions) != 0 {
+		message["suggestedResponses"] = suggestions
+	}
+	return final("Success", "", message)
+}
+
+// Error ends the turn with the given result value, e.g. Throttled.
+func Error(value string, message string) Frame {
+	return final(value, message, nil)
+}
+
+// Captcha ends the turn with the error Bing returns when a CAPTCHA has to be solved.
+func Captcha() Frame {
+	return Error("CaptchaChallenge", "User needs to solve CAPTCHA to continue.")
+}
+
+// Disconnect closes the websocket abruptly.
+func Disconnect() Frame {
+	return Frame{disconnect: true}
+}
+
+func update(message map[string]any) Frame {
+	return marshal(map[string]any{
+		"type":   1,
+		"target": "update",
+		"arguments": []any{
+			map[string]any{
+				"messages": []any{message},
+			},
+		},
+	})
+}
+func final(value string, errMessage string, message map[string]any) Frame {
+	item := map[string]any{
+		"result": map[string]any{
+			"value":   value,
+			"message": errMessage,
+		},
+		"throttling": map[string]any{
+			"maxNumUserMessagesInConversation": 30,
+			"numUserMessagesInConversation":    numUserMessagesPlaceholder,
+		},
+	}
+	if message != nil {
+		item["messages"] = []any{message}
+	}
+	frame := marshal(map[string]any{
+		"type":         2,
+		"invocationId": "0",
+		"item":         item,
+	})
+	// the placeholder must be a json number after replacement
+	frame.data = strings.Replace(frame.data, `"`+numUserMessagesPlaceholder+`"`, numUserMessagesPlaceholder, 1)
+	return frame
+}
+func marshal(v any) Frame {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return Frame{data: string(data)}
+}
@@ -0,0 +1,277 @@
+// Package sydneytest provides a fake Bing ChatHub server for testing the sydney package without network.
+package sydneytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+const delimiter = "\x1e"
+
+// Server is a local HTTP + websocket server implementing the endpoints used by sydney.Sydney:
+// conversation creation, the ChatHub websocket, file uploading and a CAPTCHA bypass server.
+// Each chat request received by the ChatHub plays the next scripted turn.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	turns         [][]Frame
+	requests      []string
+	createStatus  int
+	createFails   int // remaining failures of conversation creation, negative for unlimited
+	conversations int
+	pings         int
+	userMessages  map[string]int // by conversation id
+	uploads       []string       // file names
+}
+
+func NewServer() *Server {
+	o := &Server{userMessages: map[string]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/turing/conversation/create", o.handleCreate)
+	mux.HandleFunc("/sydney/ChatHub", o.handleChatHub)
+	mux.HandleFunc("/sydney/UploadFile", o.handleUploadFile)
+	mux.HandleFunc("/captcha/bypass", o.handleBypass)
+	o.Server = httptest.NewServer(mux)
+	return o
+}
+
+// CreateConversationURL is meant for sydney.Options.CreateConversationURL.
+func (o *Server) CreateConversationURL() string {
+	return o.URL + "/turing/conversation/create"
+}
+
+// WssDomain is meant for sydney.Options.WssDomain.
+func (o *Server) WssDomain() string {
+	return "ws" + strings.TrimPrefix(o.URL, "http")
+}
+
+// UploadFileURL is meant to replace the file uploading endpoint of sydney.Sydney.
+// Files whose names start with "fail" are rejected.
+func (o *Server) UploadFileURL() string {
+	return o.URL + "/sydney/UploadFile"
+}
+
+// BypassServer is meant for sydney.Options.BypassServer. It always resolves the CAPTCHA.
+func (o *Server) BypassServer() string {
+	return o.URL + "/captcha/bypass"
+}
+
+// Script appends a turn to be played for the next chat request.
+func (o *Server) Script(frames ...Frame) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.turns = append(o.turns, frames)
+}
+
+// FailCreate makes conversation creation respond with the given status code. Pass 0 to recover.
+func (o *Server) FailCreate(statusCode int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.createStatus = statusCode
+	o.createFails = -1
+}
+
+// FailCreateTimes makes the next n conversation creations respond with the given status code.
+func (o *Server) FailCreateTimes(statusCode int, n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.createStatus = statusCode
+	o.createFails = n
+}
+
+// Requests returns the raw json of all chat requests received so far.
+func (o *Server) Requests() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.requests...)
+}
+
+// Pings returns the number of keepalive pings received after chat requests so far.
+func (o *Server) Pings() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pings
+}
+
+// Uploads returns the names of the files uploaded so far.
+func (o *Server) Uploads() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.uploads...)
+}
+
+// Conversations returns the number of conversations created so far.
+func (o *Server) Conversations() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.conversations
+}
+
+func (o *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	status := o.createStatus
+	if o.createFails > 0 {
+		o.createFails--
+		if o.createFails == 0 {
+			o.createStatus = 0
+		}
+	}
+	if status == 0 {
+		o.conversations++
+	}
+	n := o.conversations
+	o.mu.Unlock()
+	if status != 0 {
+		http.Error(w, "fake create conversation failure", status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Sydney-Encryptedconversationsignature", "fake-sec-access-token")
+	w.Header().Set("X-Sydney-Conversationsignature", "fake-bearer-token")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"conversationId": "Conversation-" + strconv.Itoa(n),
+		"clientId":       "Client-" + strconv.Itoa(n),
+		"result": map[string]any{
+			"value":   "Success",
+			"message": nil,
+		},
+	})
+}
+
+func (o *Server) handleUploadFile(w http.ResponseWriter, r *http.Request) {
+	_, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := map[string]any{"value": "Success", "message": ""}
+	if strings.HasPrefix(header.Filename, "fail") {
+		result = map[string]any{"value": "Failure", "message": "fake upload failure"}
+	} else {
+		o.mu.Lock()
+		o.uploads = append(o.uploads, header.Filename)
+		o.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"fileName": header.Filename,
+		"fileSize": header.Size,
+		"docId":    "Doc-" + header.Filename,
+		"userId":   r.FormValue("userId"),
+		"result":   result,
+	})
+}
+
+func (o *Server) handleBypass(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"result": map[string]any{
+			"cookies":    "cct=fake-cct",
+			"screenshot": "",
+		},
+	})
+}
+
+func (o *Server) handleChatHub(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(-1)
+	ctx := r.Context()
+	// handshake: {"protocol": "json", "version": 1}
+	if _, _, err = conn.Read(ctx); err != nil {
+		return
+	}
+	if err = conn.Write(ctx, websocket.MessageText, []byte("{}"+delimiter)); err != nil {
+		return
+	}
+	request, err := readChatRequest(conn, r)
+	if err != nil {
+		return
+	}
+	o.mu.Lock()
+	o.requests = append(o.requests, request.raw)
+	o.userMessages[request.ConversationID]++
+	numUserMessages := o.userMessages[request.ConversationID]
+	var frames []Frame
+	if len(o.turns) != 0 {
+		frames = o.turns[0]
+		o.turns = o.turns[1:]
+	} else {
+		frames = []Frame{Error("InvalidRequest", "no scripted turn left")}
+	}
+	o.mu.Unlock()
+	readerDone := make(chan struct{})
+	go func() { // count keepalive pings until the client goes away
+		defer close(readerDone)
+		for {
+			_, v, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			if strings.Contains(string(v), `"type": 6`) {
+				o.mu.Lock()
+				o.pings++
+				o.mu.Unlock()
+			}
+		}
+	}()
+	for _, frame := range frames {
+		if frame.delay != 0 {
+			select {
+			case <-time.After(frame.delay):
+			case <-readerDone:
+				return
+			}
+		}
+		if frame.disconnect {
+			return
+		}
+		data := strings.ReplaceAll(frame.data, numUserMessagesPlaceholder, strconv.Itoa(numUserMessages))
+		if err = conn.Write(ctx, websocket.MessageText, []byte(data+delimiter)); err != nil {
+			return
+		}
+	}
+	<-readerDone
+}
+
+type chatRequest struct {
+	raw            string
+	ConversationID string
+}
+
+// readChatRequest skips pings until the chat invocation (type 4) arrives.
+func readChatRequest(conn *websocket.Conn, r *http.Request) (chatRequest, error) {
+	for {
+		_, v, err := conn.Read(r.Context())
+		if err != nil {
+			return chatRequest{}, err
+		}
+		for _, item := range strings.Split(string(v), delimiter) {
+			var message struct {
+				Type      int `json:"type"`
+				Arguments []struct {
+					ConversationId string `json:"conversationId"`
+				} `json:"arguments"`
+			}
+			if json.Unmarshal([]byte(item), &message) != nil || message.Type != 4 {
+				continue
+			}
+			request := chatRequest{raw: item}
+			if len(message.Arguments) != 0 {
+				request.ConversationID = message.Arguments[0].ConversationId
+			}
+			return request, nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package sydney
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TranscriptRecord is a line of a transcript file. The first line of a transcript
+// describes the turn, and the following lines are the raw messages received.
+type TranscriptRecord struct {
+	Time           time.Time `json:"time"`
+	Prompt         string    `json:"prompt,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Data           string    `json:"data,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	ErrorKind      string    `json:"error_kind,omitempty"` // the sentinel error of Error, e.g. throttled
+}
+
+// recordTranscript writes every message of ch to a new transcript file while passing it through.
+func (o *Sydney) recordTranscript(options AskStreamOptions, conversation CreateConversationResponse,
+	ch <-chan RawMessage) <-chan RawMessage {
+	err := os.MkdirAll(o.transcriptDir, 0750)
+	if err != nil {
+		slog.Warn("Cannot create transcript dir", "err", err)
+		return ch
+	}
+	filePath := filepath.Join(o.transcriptDir,
+		"transcript_"+time.Now().Format("2006-01-02_15-04-05.000")+".jsonl")
+	f, err := os.Create(filePath)
+	if err != nil {
+		slog.Warn("Cannot create transcript file", "err", err)
+		return ch
+	}
+	slog.Info("Recording transcript", "path", filePath)
+	encoder := json.NewEncoder(f)
+	write := func(record TranscriptRecord) {
+		record.Time = time.Now()
+		if err := encoder.Encode(&record); err != nil {
+			slog.Warn("Cannot write transcript", "err", err)
+		}
+	}
+	write(TranscriptRecord{Prompt: options.Prompt, ConversationID: conversation.ConversationId})
+	out := make(chan RawMessage)
+	go func() {
+		defer close(out)
+		defer f.Close()
+		for msg := range ch {
+			record := TranscriptRecord{Data: msg.Data}
+			if msg.Error != nil {
+				record.Error = msg.Error.Error()
+				record.ErrorKind = errorKind(msg.Error)
+			}
+			write(record)
+			out <- msg
+		}
+	}()
+	return out
+}
+
+// ReadTranscript reads the raw messages of a transcript.
+func ReadTranscript(r io.Reader) ([]RawMessage, error) {
+	var messages []RawMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record TranscriptRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		switch {
+		case record.Error != "":
+			messages = append(messages, RawMessage{Error: classify(errorOfKind(record.ErrorKind),
+				errors.New(record.Error))})
+		case record.Data != "":
+			messages = append(messages, RawMessage{Data: record.Data})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ReplayTranscript feeds a recorded transcript through the same decoding logic as AskStream,
+// without any network. CAPTCHA errors are reported as is instead of being resolved.
+func (o *Sydney) ReplayTranscript(transcript io.Reader) (<-chan Message, error) {
+	messages, err := ReadTranscript(transcript)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan RawMessage, len(messages)) // buffered, so that decoding can stop early
+	for _, msg := range messages {
+		ch <- msg
+	}
+	close(ch)
+	return o.decodeStream(AskStreamOptions{
+		StopCtx: context.Background(),
+		replay:  true,
+	}, CreateConversationResponse{}, ch), nil
+}
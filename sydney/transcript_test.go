@@ -0,0 +1,90 @@
+package sydney
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sydneyqt/sydney/sydneytest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files of transcript tests")
+
+type goldenMessage struct {
+	Type               string            `json:"type"`
+	Text               string            `json:"text"`
+	SuggestedResponses []string          `json:"suggested_responses,omitempty"`
+	SearchResult       []SourceAttribute `json:"search_result,omitempty"`
+	GenerativeImage    *GenerativeImage  `json:"generative_image,omitempty"`
+	GenerativeMusic    *GenerativeMusic  `json:"generative_music,omitempty"`
+	GeneratedCode      *GeneratedCode    `json:"generated_code,omitempty"`
+	CodeOutput         []CodeOutputFile  `json:"code_output,omitempty"`
+}
+
+func TestReplayTranscript(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "transcripts", "*.jsonl"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, files)
+	syd := NewSydney(Options{})
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := os.Open(file)
+			assert.Nil(t, err)
+			defer f.Close()
+			ch, err := syd.ReplayTranscript(f)
+			assert.Nil(t, err)
+			var messages []goldenMessage
+			for msg := range ch {
+				messages = append(messages, goldenMessage{
+					Type:               msg.Type,
+					Text:               msg.Text,
+					SuggestedResponses: msg.SuggestedResponses,
+					SearchResult:       msg.SearchResult,
+					GenerativeImage:    msg.GenerativeImage,
+					GenerativeMusic:    msg.GenerativeMusic,
+					GeneratedCode:      msg.GeneratedCode,
+					CodeOutput:         msg.CodeOutput,
+				})
+			}
+			v, err := json.MarshalIndent(messages, "", "  ")
+			assert.Nil(t, err)
+			goldenFile := strings.TrimSuffix(file, ".jsonl") + ".golden.json"
+			if *updateGolden {
+				assert.Nil(t, os.WriteFile(goldenFile, v, 0644))
+			}
+			expected, err := os.ReadFile(goldenFile)
+			assert.Nil(t, err)
+			assert.JSONEq(t, string(expected), string(v))
+		})
+	}
+}
+func TestReplayTranscriptErrorKind(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	syd.transcriptDir = t.TempDir()
+	server.Script(sydneytest.Text("Hello"), sydneytest.Error("Throttled", "Too many requests"))
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{StopCtx: context.Background(), Prompt: "hi"}))
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, messages) {
+		assert.ErrorIs(t, messages[len(messages)-1].Error, ErrThrottled)
+	}
+	files, err := filepath.Glob(filepath.Join(syd.transcriptDir, "*.jsonl"))
+	assert.Nil(t, err)
+	if !assert.Len(t, files, 1) {
+		return
+	}
+	f, err := os.Open(files[0])
+	assert.Nil(t, err)
+	defer f.Close()
+	replayed, err := collectMessages(syd.ReplayTranscript(f))
+	assert.Nil(t, err)
+	if assert.Len(t, replayed, len(messages)) {
+		last := replayed[len(replayed)-1]
+		assert.ErrorIs(t, last.Error, ErrThrottled)
+		assert.Equal(t, messages[len(messages)-1].Text, last.Text)
+	}
+}
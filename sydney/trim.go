@@ -0,0 +1,252 @@
+package sydney
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sydneyqt/util"
+
+	"github.com/samber/lo"
+)
+
+const (
+	TrimStrategyDropOldest         = "drop_oldest"
+	TrimStrategyStripSearchResults = "strip_search_results"
+	TrimStrategySummarize          = "summarize"
+)
+
+// TrimStrategies returns the names of the strategies to trim the context with.
+func TrimStrategies() []string {
+	return []string{TrimStrategyDropOldest, TrimStrategyStripSearchResults, TrimStrategySummarize}
+}
+
+// ContextTrim describes how the context has been trimmed to fit the token budget.
+type ContextTrim struct {
+	Strategy           string `json:"strategy"`
+	TokensBefore       int    `json:"tokens_before"`
+	TokensAfter        int    `json:"tokens_after"`
+	DroppedMessages    int    `json:"dropped_messages"`
+	StrippedMessages   int    `json:"stripped_messages"`
+	SummarizedMessages int    `json:"summarized_messages"`
+	Description        string `json:"description"`
+}
+
+// summaryRatio is the part of the token budget reserved for the summary of older turns.
+const summaryRatio = 5
+
+const summarizePrompt = "Summarize the conversation above in a few paragraphs. " +
+	"Keep the facts, names and decisions needed to continue it. Reply with the summary only."
+
+// strippableTypes are the assistant message types removed by TrimStrategyStripSearchResults.
+var strippableTypes = []string{MessageTypeSearchQuery, MessageTypeSearchResult, MessageTypeLoading}
+
+// chatTagPattern matches the tag starting a message of the context, as in util.GetChatMessage.
+var chatTagPattern = regexp.MustCompile(`(?i)\[(system|user|assistant)]\(#.*?\)`)
+
+type trimItem struct {
+	util.ChatMessage
+	tokens int
+}
+
+func sumTokens(items []trimItem) int {
+	return lo.SumBy(items, func(item trimItem) int {
+		return item.tokens
+	})
+}
+
+// trimContext trims options.WebpageContext so that it fits options.TokenBudget along with options.Prompt.
+// It returns nil if nothing has been trimmed. Untagged text before the first message, e.g. a document,
+// leading system messages, e.g. instructions, and the last turn are never trimmed.
+// If the strategy cannot make it fit, the oldest turns are dropped.
+func trimContext(options AskStreamOptions) (string, *ContextTrim) {
+	countToken := lo.Ternary(options.CountToken == nil, util.CountToken, options.CountToken)
+	budget := options.TokenBudget - countToken(options.Prompt)
+	before := countToken(options.WebpageContext)
+	if options.TokenBudget <= 0 || before <= budget {
+		return options.WebpageContext, nil
+	}
+	messages := util.GetChatMessage(options.WebpageContext)
+	if loc := chatTagPattern.FindStringIndex(options.WebpageContext); loc != nil {
+		if preamble := strings.TrimSpace(options.WebpageContext[:loc[0]]); preamble != "" {
+			messages = append([]util.ChatMessage{{Content: preamble}}, messages...)
+		}
+	}
+	items := lo.Map(messages, func(msg util.ChatMessage, _ int) trimItem {
+		return trimItem{ChatMessage: msg, tokens: countToken(formatChatMessages([]util.ChatMessage{msg}))}
+	})
+	if len(items) == 0 {
+		return options.WebpageContext, nil
+	}
+	trim := ContextTrim{
+		Strategy:     lo.Ternary(options.TrimStrategy == "", TrimStrategyDropOldest, options.TrimStrategy),
+		TokensBefore: before,
+	}
+	pinned := 0
+	for pinned < len(items) && (items[pinned].Role == "" || items[pinned].Role == "system") {
+		pinned++
+	}
+	switch trim.Strategy {
+	case TrimStrategyDropOldest:
+	case TrimStrategyStripSearchResults:
+		lastTurn := lastTurnIndex(items, pinned)
+		total := sumTokens(items)
+		var kept []trimItem
+		for i, item := range items {
+			if total > budget && i >= pinned && i < lastTurn &&
+				item.Role == "assistant" && lo.Contains(strippableTypes, item.Type) {
+				total -= item.tokens
+				trim.StrippedMessages++
+				continue
+			}
+			kept = append(kept, item)
+		}
+		items = kept
+	case TrimStrategySummarize:
+		if options.Summarize == nil {
+			slog.Warn("No summarizer for trimming the context, falling back to dropping the oldest turns")
+			trim.Strategy = TrimStrategyDropOldest
+			break
+		}
+		kept, dropped := dropOldestTurns(items, pinned, budget-budget/summaryRatio)
+		if len(dropped) == 0 {
+			break
+		}
+		summary, err := options.Summarize(options.StopCtx,
+			formatChatMessages(lo.Map(dropped, func(item trimItem, _ int) util.ChatMessage {
+				return item.ChatMessage
+			})))
+		if err != nil {
+			slog.Warn("Cannot summarize the context, falling back to dropping the oldest turns", "err", err)
+			trim.Strategy = TrimStrategyDropOldest
+			break
+		}
+		summaryMessage := util.ChatMessage{Role: "system", Type: "context_summary", Content: strings.TrimSpace(summary)}
+		items = append(append(kept[:pinned:pinned], trimItem{
+			ChatMessage: summaryMessage,
+			tokens:      countToken(formatChatMessages([]util.ChatMessage{summaryMessage})),
+		}), kept[pinned:]...)
+		pinned++ // the summary is kept as well
+		trim.SummarizedMessages = len(dropped)
+	default:
+		slog.Warn("Unknown trim strategy, falling back to dropping the oldest turns", "strategy", trim.Strategy)
+		trim.Strategy = TrimStrategyDropOldest
+	}
+	var dropped []trimItem
+	items, dropped = dropOldestTurns(items, pinned, budget)
+	trim.DroppedMessages = len(dropped)
+	result := formatChatMessages(lo.Map(items, func(item trimItem, _ int) util.ChatMessage {
+		return item.ChatMessage
+	}))
+	trim.TokensAfter = countToken(result)
+	trim.Description = trim.describe()
+	slog.Info("Trimmed the context", "trim", trim)
+	return result, &trim
+}
+func (o ContextTrim) describe() string {
+	var actions []string
+	if o.SummarizedMessages != 0 {
+		actions = append(actions, fmt.Sprintf("%d older messages summarized", o.SummarizedMessages))
+	}
+	if o.StrippedMessages != 0 {
+		actions = append(actions, fmt.Sprintf("%d search blocks stripped", o.StrippedMessages))
+	}
+	if o.DroppedMessages != 0 {
+		actions = append(actions, fmt.Sprintf("%d oldest messages dropped", o.DroppedMessages))
+	}
+	if len(actions) == 0 {
+		actions = append(actions, "nothing could be trimmed")
+	}
+	return fmt.Sprintf("Chat context trimmed from %d to %d tokens: %s.",
+		o.TokensBefore, o.TokensAfter, strings.Join(actions, ", "))
+}
+
+// lastTurnIndex returns the index of the last user message after the pinned ones.
+func lastTurnIndex(items []trimItem, pinned int) int {
+	for i := len(items) - 1; i > pinned; i-- {
+		if items[i].Role == "user" {
+			return i
+		}
+	}
+	return pinned
+}
+
+// dropOldestTurns drops turns, each starting with a user message, after the pinned messages
+// until the rest fits the budget. The last turn is never dropped.
+func dropOldestTurns(items []trimItem, pinned int, budget int) ([]trimItem, []trimItem) {
+	total := sumTokens(items)
+	start := pinned
+	for total > budget {
+		end := start + 1
+		for end < len(items) && items[end].Role != "user" {
+			end++
+		}
+		if end >= len(items) { // only the last turn is left
+			break
+		}
+		total -= sumTokens(items[start:end])
+		start = end
+	}
+	kept := append(items[:pinned:pinned], items[start:]...)
+	return kept, items[pinned:start]
+}
+
+// formatChatMessages is the reverse of util.GetChatMessage. Messages without a role are written untagged.
+func formatChatMessages(messages []util.ChatMessage) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		if msg.Role == "" {
+			sb.WriteString(msg.Content + "\n\n")
+			continue
+		}
+		sb.WriteString("[" + msg.Role + "](#" + msg.Type + ")\n" + msg.Content + "\n\n")
+	}
+	return sb.String()
+}
+
+// withContextTrim sends a MessageTypeContextTrimmed message describing trim before the messages of ch.
+func withContextTrim(trim *ContextTrim, ch <-chan Message) <-chan Message {
+	out := make(chan Message)
+	go func() {
+		defer close(out)
+		v, err := json.Marshal(trim)
+		if err != nil {
+			util.GracefulPanic(err)
+		}
+		out <- Message{
+			Type:        MessageTypeContextTrimmed,
+			Text:        string(v),
+			ContextTrim: trim,
+		}
+		for msg := range ch {
+			out <- msg
+		}
+	}()
+	return out
+}
+
+// SummarizeWith returns a summarizer for AskStreamOptions.Summarize that asks syd to summarize the text.
+func SummarizeWith(syd *Sydney) func(ctx context.Context, text string) (string, error) {
+	return func(ctx context.Context, text string) (string, error) {
+		ch, err := syd.AskStream(AskStreamOptions{
+			StopCtx:        ctx,
+			Prompt:         summarizePrompt,
+			WebpageContext: text,
+		})
+		if err != nil {
+			return "", err
+		}
+		var sb strings.Builder
+		for msg := range ch {
+			if msg.Type == MessageTypeError {
+				return "", msg.Error
+			}
+			if msg.Type == MessageTypeMessageText {
+				sb.WriteString(msg.Text)
+			}
+		}
+		return sb.String(), nil
+	}
+}
@@ -0,0 +1,210 @@
+package sydney
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sydneyqt/sydney/sydneytest"
+	"sydneyqt/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+// countWords avoids downloading the tiktoken encoding in tests.
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+const trimTestContext = `[system](#additional_instructions)
+Be nice.
+
+[user](#message)
+one two three four
+
+[assistant](#search_query)
+four five
+
+[assistant](#search_result)
+six seven eight nine ten eleven twelve
+
+[assistant](#message)
+thirteen fourteen
+
+[user](#message)
+fifteen sixteen
+
+[assistant](#search_result)
+seventeen eighteen nineteen
+
+[assistant](#message)
+twenty
+`
+
+func TestTrimContextWithinBudget(t *testing.T) {
+	text, trim := trimContext(AskStreamOptions{
+		WebpageContext: trimTestContext,
+		TokenBudget:    1000,
+		CountToken:     countWords,
+	})
+	assert.Nil(t, trim)
+	assert.Equal(t, trimTestContext, text)
+}
+func TestTrimContextDropOldest(t *testing.T) {
+	text, trim := trimContext(AskStreamOptions{
+		WebpageContext: trimTestContext,
+		TokenBudget:    20,
+		CountToken:     countWords,
+	})
+	if assert.NotNil(t, trim) {
+		assert.Equal(t, TrimStrategyDropOldest, trim.Strategy)
+		assert.Equal(t, 4, trim.DroppedMessages)
+		assert.LessOrEqual(t, trim.TokensAfter, 20)
+		assert.Contains(t, trim.Description, "4 oldest messages dropped")
+	}
+	messages := util.GetChatMessage(text)
+	if assert.Len(t, messages, 4) {
+		assert.Equal(t, "additional_instructions", messages[0].Type)
+		assert.Equal(t, "fifteen sixteen", messages[1].Content)
+	}
+}
+func TestTrimContextKeepsUntaggedText(t *testing.T) {
+	text, trim := trimContext(AskStreamOptions{
+		WebpageContext: "The document:\nlorem ipsum dolor\n\n" + trimTestContext,
+		TokenBudget:    25,
+		CountToken:     countWords,
+	})
+	if assert.NotNil(t, trim) {
+		assert.Equal(t, 4, trim.DroppedMessages)
+	}
+	assert.True(t, strings.HasPrefix(text, "The document:\nlorem ipsum dolor\n\n[system](#additional_instructions)\n"))
+	assert.NotContains(t, text, "one two three four")
+	assert.Contains(t, text, "fifteen sixteen")
+}
+func TestTrimContextStripSearchResults(t *testing.T) {
+	text, trim := trimContext(AskStreamOptions{
+		WebpageContext: trimTestContext,
+		TokenBudget:    25,
+		TrimStrategy:   TrimStrategyStripSearchResults,
+		CountToken:     countWords,
+	})
+	if assert.NotNil(t, trim) {
+		assert.Equal(t, 2, trim.StrippedMessages)
+		assert.Equal(t, 0, trim.DroppedMessages)
+	}
+	assert.NotContains(t, text, "six seven")
+	assert.Contains(t, text, "one two three four")
+	assert.Contains(t, text, "seventeen eighteen nineteen") // the last turn is kept as is
+}
+func TestTrimContextSummarize(t *testing.T) {
+	var summarized string
+	text, trim := trimContext(AskStreamOptions{
+		StopCtx:        context.Background(),
+		WebpageContext: trimTestContext,
+		TokenBudget:    25,
+		TrimStrategy:   TrimStrategySummarize,
+		CountToken:     countWords,
+		Summarize: func(ctx context.Context, text string) (string, error) {
+			summarized = text
+			return "Counting words.", nil
+		},
+	})
+	if assert.NotNil(t, trim) {
+		assert.Equal(t, 4, trim.SummarizedMessages)
+		assert.Equal(t, 0, trim.DroppedMessages)
+	}
+	assert.Contains(t, summarized, "one two three four")
+	messages := util.GetChatMessage(text)
+	if assert.Len(t, messages, 5) {
+		assert.Equal(t, util.ChatMessage{Role: "system", Type: "context_summary", Content: "Counting words."}, messages[1])
+	}
+}
+func TestTrimContextSummarizeFailure(t *testing.T) {
+	_, trim := trimContext(AskStreamOptions{
+		StopCtx:        context.Background(),
+		WebpageContext: trimTestContext,
+		TokenBudget:    20,
+		TrimStrategy:   TrimStrategySummarize,
+		CountToken:     countWords,
+		Summarize: func(ctx context.Context, text string) (string, error) {
+			return "", errors.New("fake failure")
+		},
+	})
+	if assert.NotNil(t, trim) {
+		assert.Equal(t, TrimStrategyDropOldest, trim.Strategy)
+		assert.Equal(t, 0, trim.SummarizedMessages)
+		assert.Equal(t, 4, trim.DroppedMessages)
+	}
+}
+func TestTrimContextUnknownStrategy(t *testing.T) {
+	_, trim := trimContext(AskStreamOptions{
+		WebpageContext: trimTestContext,
+		TokenBudget:    20,
+		TrimStrategy:   "random",
+		CountToken:     countWords,
+	})
+	if assert.NotNil(t, trim) {
+		assert.Equal(t, TrimStrategyDropOldest, trim.Strategy)
+		assert.Equal(t, 4, trim.DroppedMessages)
+	}
+}
+func TestAskStreamContextTrimmed(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	server.Script(sydneytest.Text("Hello"), sydneytest.Finish())
+	messages, err := collectMessages(syd.AskStream(AskStreamOptions{
+		StopCtx:        context.Background(),
+		Prompt:         "hi",
+		WebpageContext: trimTestContext,
+		TokenBudget:    20,
+		CountToken:     countWords,
+	}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, MessageTypeContextTrimmed, messages[0].Type)
+		assert.NotNil(t, messages[0].ContextTrim)
+		assert.Equal(t, "Hello", messages[1].Text)
+	}
+	requests := server.Requests()
+	if assert.Len(t, requests, 1) {
+		description := gjson.Get(requests[0], "arguments.0.previousMessages.0.description").String()
+		assert.NotContains(t, description, "one two three four")
+		assert.Contains(t, description, "fifteen sixteen")
+	}
+}
+func TestAskStreamContextTrimmedInNewSessionConversation(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	session := NewSession()
+	server.Script(sydneytest.Text("Hello"), sydneytest.Finish())
+	_, err := collectMessages(syd.AskStream(AskStreamOptions{
+		StopCtx: context.Background(),
+		Session: session,
+		Prompt:  "hi",
+	}))
+	assert.Nil(t, err)
+	// another account starts a new conversation, which is sent the context again
+	other := NewSydney(Options{
+		Cookies:               map[string]string{"_U": "other"},
+		WssDomain:             server.WssDomain(),
+		CreateConversationURL: server.CreateConversationURL(),
+	})
+	server.Script(sydneytest.Text("Hello again"), sydneytest.Finish())
+	messages, err := collectMessages(other.AskStream(AskStreamOptions{
+		StopCtx:        context.Background(),
+		Session:        session,
+		Prompt:         "hi",
+		WebpageContext: trimTestContext,
+		TokenBudget:    20,
+		CountToken:     countWords,
+	}))
+	assert.Nil(t, err)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, MessageTypeContextTrimmed, messages[0].Type)
+	}
+	requests := server.Requests()
+	if assert.Len(t, requests, 2) {
+		description := gjson.Get(requests[1], "arguments.0.previousMessages.0.description").String()
+		assert.NotContains(t, description, "one two three four")
+		assert.Contains(t, description, "fifteen sixteen")
+	}
+}
@@ -55,21 +55,41 @@ const (
 	MessageTypeExecutingTask      = "executing_task"
 	MessageTypeOpenAPICall        = "openapi_call"
 	MessageTypeGeneratedCode      = "generated_code"
+	MessageTypeCodeOutput         = "code_output"
 	MessageTypeResolvingCaptcha   = "resolving_captcha"
 	MessageTypeMessageText        = "message"
 	MessageTypeSuggestedResponses = "suggested_responses"
+	MessageTypeContextTrimmed     = "context_trimmed"
 	MessageTypeError              = "error"
 )
 
 var (
 	ErrMessageRevoke   = errors.New("message revoke detected")
 	ErrMessageFiltered = errors.New("message triggered the Bing filter")
+
+	ErrCookieExpired     = errors.New("cookie expired or not logged in")
+	ErrRegionUnsupported = errors.New("region not supported")
+	ErrThrottled         = errors.New("request throttled")
+	ErrCaptchaRequired   = errors.New("CAPTCHA required")
+	ErrNetwork           = errors.New("network or proxy failure")
+	ErrContextTooLong    = errors.New("chat context too long")
+	ErrStreamStalled     = errors.New("stream stalled")
+	ErrFileTooLarge      = errors.New("file too large to upload")
 )
 
 type Message struct {
 	Type  string
-	Text  string
+	Text  string // For structured payloads, the json of the typed field below.
 	Error error
+
+	// Typed payloads, only the one matching Type is set.
+	SuggestedResponses []string          // MessageTypeSuggestedResponses
+	SearchResult       []SourceAttribute // MessageTypeSearchResult
+	GenerativeImage    *GenerativeImage  // MessageTypeGenerativeImage
+	GenerativeMusic    *GenerativeMusic  // MessageTypeGenerativeMusic
+	ContextTrim        *ContextTrim      // MessageTypeContextTrimmed
+	GeneratedCode      *GeneratedCode    // MessageTypeGeneratedCode, whose Text is the code
+	CodeOutput         []CodeOutputFile  // MessageTypeCodeOutput
 }
 type ChatMessage struct {
 	Arguments    []Argument `json:"arguments"`
@@ -143,6 +163,32 @@ type Options struct {
 	GPT4Turbo             bool
 	BypassServer          string
 	Plugins               []string
+	TranscriptDir         string      // Write raw ChatHub messages of every turn to a JSONL file in it. Optional.
+	RetryPolicy           RetryPolicy // Defaults to DefaultRetryPolicy if MaxAttempts is 0.
+	Location              Location    // Defaults to DefaultLocation. See LocationList for the built-in ones.
+	// Interval of the pings sent while waiting for the answer. Defaults to DefaultKeepaliveInterval.
+	KeepaliveInterval time.Duration
+	// Timeout of the websocket handshake response. Defaults to DefaultReadTimeout.
+	ReadTimeout time.Duration
+	// How long the answer may receive no message before failing with ErrStreamStalled.
+	// Defaults to DefaultIdleTimeout.
+	IdleTimeout time.Duration
+	// Interval between polls of image and music creation results. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// How many times to poll before image or music creation times out. Defaults to DefaultPollAttempts.
+	PollAttempts int
+	// Name of a solver registered by RegisterCaptchaSolver, e.g. CaptchaSolverFail. Defaults to
+	// CaptchaSolverBypass if BypassServer is set and CaptchaSolverBrowser otherwise.
+	CaptchaSolverName string
+	// Takes precedence over CaptchaSolverName. Optional.
+	CaptchaSolver CaptchaSolver
+	// Called with a copy of all the cookies after Bing or the CAPTCHA solver updates some of them,
+	// e.g. to persist them. Optional.
+	OnCookiesUpdated func(cookies map[string]string)
+	// Limit in bytes of each file attached by AskStreamOptions, after conversion. Defaults to DefaultUploadMaxSize.
+	UploadMaxSize int
+	// Reuse the uploads of the same images and files. Optional.
+	UploadCache *UploadCache
 }
 type AskStreamOptions struct {
 	StopCtx        context.Context
@@ -150,9 +196,32 @@ type AskStreamOptions struct {
 	WebpageContext string
 	ImageURL       string
 	UploadFilePath string
+	// More files to attach to the prompt along with UploadFilePath. They are uploaded concurrently
+	// into the same conversation.
+	UploadFilePaths []string
+	// Called when the upload of each file starts, succeeds or fails, possibly from several goroutines. Optional.
+	OnUploadProgress func(progress UploadProgress)
+	// Reuse the conversation of the session, if it has been created with the same cookies. Optional.
+	Session *Session
+
+	// Continue the answer automatically when Bing revokes it, by asking RevokeReplyText
+	// in a new conversation with the partial answer as context, at most RevokeReplyCount times.
+	// Disabled if either of them is empty.
+	RevokeReplyText  string
+	RevokeReplyCount int
+	// Replace footnote markers like [^1^] in the message text with markdown links to the search results,
+	// followed by a references section. The search_result messages are still sent.
+	RewriteCitations bool
+	// Trim WebpageContext before sending if it exceeds TokenBudget tokens along with Prompt.
+	// The stream then starts with a MessageTypeContextTrimmed message. Disabled if 0.
+	TokenBudget  int
+	TrimStrategy string                                                 // Defaults to TrimStrategyDropOldest.
+	CountToken   func(text string) int                                  // Defaults to util.CountToken.
+	Summarize    func(ctx context.Context, text string) (string, error) // Required by TrimStrategySummarize.
 
 	messageID            string // A random uuid. Optional.
 	disableCaptchaBypass bool
+	replay               bool // Messages are read from a transcript.
 }
 type UploadImagePayload struct {
 	ImageInfo        map[string]any   `json:"imageInfo"`
@@ -241,8 +310,23 @@ type UploadFileResponse struct {
 	} `json:"result"`
 }
 type UploadFileResult struct {
-	Valid          bool
-	Response       UploadFileResponse
-	FileHiddenText string
-	RealFileType   string
+	Response     UploadFileResponse   `json:"response"`
+	HiddenText   UploadFileHiddenText `json:"hidden_text"`
+	RealFileType string               `json:"real_file_type"`
+}
+
+const (
+	UploadStatusUploading = "uploading"
+	UploadStatusUploaded  = "uploaded"
+	UploadStatusFailed    = "failed"
+	UploadStatusCached    = "cached" // the earlier upload of the same file is reused
+)
+
+// UploadProgress reports the upload of a file attached by AskStreamOptions.
+type UploadProgress struct {
+	Path   string `json:"path"`
+	Index  int    `json:"index"` // of the file in all the files to upload
+	Total  int    `json:"total"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
 }
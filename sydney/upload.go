@@ -2,28 +2,37 @@ package sydney
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/imroc/req/v3"
-	"github.com/samber/lo"
 	"io"
 	"log/slog"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sydneyqt/util"
+	"sync"
 	"time"
 )
 
 func (o *Sydney) UploadImage(jpgImgData []byte) (string, error) {
+	return o.UploadImageContext(context.Background(), jpgImgData)
+}
+
+// UploadImageContext uploads the image, giving up once ctx is done.
+func (o *Sydney) UploadImageContext(ctx context.Context, jpgImgData []byte) (string, error) {
+	cacheKey := uploadCacheKey("image", jpgImgData)
+	if entry, ok := o.uploadCache.get(cacheKey); ok {
+		slog.Info("Reuse uploaded image", "url", entry.ImageURL)
+		return entry.ImageURL, nil
+	}
 	_, client, err := util.MakeHTTPClient(o.proxy, 60*time.Second)
 	if err != nil {
 		return "", err
 	}
-	client.SetCommonHeader("Referer", "https://www.bing.com/search?q=Bing+AI&showconv=1&FORM=hpcodx")
 	imageBase64 := base64.StdEncoding.EncodeToString(jpgImgData)
 	uploadImagePayload := UploadImagePayload{
 		ImageInfo: map[string]any{},
@@ -43,7 +52,9 @@ func (o *Sydney) UploadImage(jpgImgData []byte) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("cannot marshal uploadImagePayload: %w", err)
 	}
-	resp, err := client.R().EnableForceMultipart().SetFormData(map[string]string{
+	resp, err := client.R().SetContext(ctx).
+		SetHeader("Referer", "https://www.bing.com/search?q=Bing+AI&showconv=1&FORM=hpcodx").
+		EnableForceMultipart().SetFormData(map[string]string{
 		"knowledgeRequest": string(payload),
 		"imageBase64":      imageBase64,
 	}).Post("https://www.bing.com/images/kblob")
@@ -58,45 +69,37 @@ func (o *Sydney) UploadImage(jpgImgData []byte) (string, error) {
 	if result.BlobId == "" {
 		return "", errors.New("blobId is empty")
 	}
-	return "https://www.bing.com/images/blob?bcid=" + result.BlobId, nil
+	imageURL := "https://www.bing.com/images/blob?bcid=" + result.BlobId
+	o.uploadCache.put(cacheKey, UploadCacheEntry{ImageURL: imageURL})
+	return imageURL, nil
 }
 
-func (o *Sydney) uploadFile(uploadFilePath string, conversation CreateConversationResponse) (UploadFileResult, error) {
+func (o *Sydney) uploadFile(ctx context.Context, upload preparedUpload,
+	conversation CreateConversationResponse) (UploadFileResult, error) {
 	var empty UploadFileResult
 	_, client, err := util.MakeHTTPClient(o.proxy, 60*time.Second)
 	if err != nil {
 		return empty, err
 	}
-	if !lo.Contains(BingAllowedFileExtensions, strings.TrimPrefix(filepath.Ext(uploadFilePath), ".")) {
-		return empty, errors.New("file type " + filepath.Ext(uploadFilePath) + " is not allowed")
-	}
-	f, err := os.ReadFile(uploadFilePath)
-	if err != nil {
-		return empty, err
-	}
-	// Seems this can be hacked
-	//if len(f) >= 1024*1024 { // 1MB
-	//	return empty, errors.New("file to upload must be less than 1MB")
-	//}
 	var response UploadFileResponse
-	resp, err := client.R().
+	resp, err := client.R().SetContext(ctx).
 		SetHeader("Authorization", "Bearer "+conversation.BearerToken).
 		SetHeader("Referer", "https://www.bing.com/search?q=Bing+AI&showconv=1").
 		SetHeader("Origin", "https://www.bing.com").
 		SetFileUpload(req.FileUpload{
 			ParamName: "file",
-			FileName:  filepath.Base(uploadFilePath),
+			FileName:  upload.FileName,
 			GetFileContent: func() (io.ReadCloser, error) {
-				return io.NopCloser(bytes.NewReader(f)), nil
+				return io.NopCloser(bytes.NewReader(upload.Data)), nil
 			},
-			FileSize:    int64(len(f)),
-			ContentType: "application/octet-stream",
+			FileSize:    int64(len(upload.Data)),
+			ContentType: upload.ContentType,
 		}).SetFormData(map[string]string{
 		"conversationId":              conversation.ConversationId,
 		"tone":                        o.conversationStyle,
 		"userId":                      conversation.ClientId,
 		"enableFileUploadLongContext": "true",
-	}).SetSuccessResult(&response).Post("https://sydney.bing.com/sydney/UploadFile")
+	}).SetSuccessResult(&response).Post(o.uploadFileURL)
 	if err != nil {
 		return empty, err
 	}
@@ -106,9 +109,10 @@ func (o *Sydney) uploadFile(uploadFilePath string, conversation CreateConversati
 	if response.Result.Value != "Success" {
 		return empty, errors.New("upload returned failed result: " + response.Result.Message)
 	}
-	realFileType := fileExtensionToFileType(filepath.Ext(uploadFilePath))
-	hiddenText := []UploadFileHiddenText{
-		{
+	realFileType := fileExtensionToFileType(filepath.Ext(upload.FileName))
+	result := UploadFileResult{
+		Response: response,
+		HiddenText: UploadFileHiddenText{
 			FileName:      response.FileName,
 			FileType:      realFileType,
 			DocId:         response.DocId,
@@ -116,22 +120,70 @@ func (o *Sydney) uploadFile(uploadFilePath string, conversation CreateConversati
 			UserId:        response.UserId,
 			IsBCE:         false,
 		},
-	}
-	v, err := json.Marshal(hiddenText)
-	if err != nil {
-		return empty, err
-	}
-	hiddenTextString := string(v)
-	result := UploadFileResult{
-		Valid:          true,
-		Response:       response,
-		FileHiddenText: hiddenTextString,
-		RealFileType:   realFileType,
+		RealFileType: realFileType,
 	}
 	slog.Info("Uploaded file", "result", result)
 	return result, nil
 }
 
+// uploadFiles uploads the files concurrently into the conversation, returning the results in the same order.
+// Files found in the upload cache are not uploaded again. Once a file fails, the other uploads are canceled.
+func (o *Sydney) uploadFiles(ctx context.Context, uploads []preparedUpload,
+	conversation CreateConversationResponse, onProgress func(progress UploadProgress)) ([]UploadFileResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	report := func(index int, status string, err error) {
+		if onProgress == nil {
+			return
+		}
+		progress := UploadProgress{
+			Path:   uploads[index].Path,
+			Index:  index,
+			Total:  len(uploads),
+			Status: status,
+		}
+		if err != nil {
+			progress.Error = err.Error()
+		}
+		onProgress(progress)
+	}
+	results := make([]UploadFileResult, len(uploads))
+	var firstErr error
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i, upload := range uploads {
+		wg.Add(1)
+		go func(i int, upload preparedUpload) {
+			defer wg.Done()
+			// uploaded documents belong to the Bing account
+			cacheKey := uploadCacheKey("file", []byte(o.cookies["_U"]), []byte(upload.FileName), upload.Data)
+			if entry, ok := o.uploadCache.get(cacheKey); ok && entry.File != nil {
+				results[i] = *entry.File
+				report(i, UploadStatusCached, nil)
+				return
+			}
+			report(i, UploadStatusUploading, nil)
+			result, err := o.uploadFile(ctx, upload, conversation)
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("cannot upload %s: %w", filepath.Base(upload.Path), err)
+					cancel()
+				})
+				report(i, UploadStatusFailed, err)
+				return
+			}
+			results[i] = result
+			o.uploadCache.put(cacheKey, UploadCacheEntry{File: &result})
+			report(i, UploadStatusUploaded, nil)
+		}(i, upload)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
 func fileExtensionToFileType(ext string) string {
 	ext = strings.TrimPrefix(ext, ".")
 	switch ext {
@@ -177,7 +229,6 @@ var BingAllowedFileExtensions = []string{
 	"tsx",
 	"m",
 	"scala",
-	"rs",
 	"dart",
 	"lua",
 	"pl",
@@ -194,7 +245,6 @@ var BingAllowedFileExtensions = []string{
 	"yaml",
 	"yml",
 	"toml",
-	"lua",
 	"sql",
 	"md",
 	"coffee",
@@ -204,6 +254,5 @@ var BingAllowedFileExtensions = []string{
 	"docx",
 	"xlsx",
 	"pptx",
-	"html",
 	"wav",
 }
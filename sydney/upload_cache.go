@@ -0,0 +1,130 @@
+package sydney
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultUploadCacheTTL is how long an upload is reused by default.
+const DefaultUploadCacheTTL = 24 * time.Hour
+
+// UploadCache remembers the results of image and file uploads by the hash of their content,
+// so that attaching the same content again within the ttl reuses the earlier upload.
+// It is persisted to a json file, and is safe to share between Sydney instances.
+type UploadCache struct {
+	mu      sync.Mutex
+	path    string // empty for an in-memory cache
+	ttl     time.Duration
+	entries map[string]UploadCacheEntry // nil until loaded
+}
+
+type UploadCacheEntry struct {
+	ImageURL  string            `json:"image_url,omitempty"`
+	File      *UploadFileResult `json:"file,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// NewUploadCache creates a cache stored at path. A ttl <= 0 means DefaultUploadCacheTTL.
+func NewUploadCache(path string, ttl time.Duration) *UploadCache {
+	if ttl <= 0 {
+		ttl = DefaultUploadCacheTTL
+	}
+	return &UploadCache{path: path, ttl: ttl}
+}
+
+// Clear removes all the entries.
+func (o *UploadCache) Clear() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries = map[string]UploadCacheEntry{}
+	return o.save()
+}
+
+func (o *UploadCache) get(key string) (UploadCacheEntry, bool) {
+	if o == nil {
+		return UploadCacheEntry{}, false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.load()
+	entry, ok := o.entries[key]
+	if !ok || time.Now().After(entry.ExpiresAt) {
+		return UploadCacheEntry{}, false
+	}
+	return entry, true
+}
+func (o *UploadCache) put(key string, entry UploadCacheEntry) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.load()
+	o.prune()
+	entry.ExpiresAt = time.Now().Add(o.ttl)
+	o.entries[key] = entry
+	if err := o.save(); err != nil {
+		slog.Warn("Cannot save upload cache", "err", err)
+	}
+}
+func (o *UploadCache) prune() {
+	now := time.Now()
+	for key, entry := range o.entries {
+		if now.After(entry.ExpiresAt) {
+			delete(o.entries, key)
+		}
+	}
+}
+func (o *UploadCache) load() {
+	if o.entries != nil {
+		return
+	}
+	o.entries = map[string]UploadCacheEntry{}
+	if o.path == "" {
+		return
+	}
+	v, err := os.ReadFile(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(v, &o.entries)
+	}
+	if err != nil {
+		slog.Warn("Cannot load upload cache, starting over", "err", err)
+		o.entries = map[string]UploadCacheEntry{}
+	}
+}
+func (o *UploadCache) save() error {
+	if o.path == "" {
+		return nil
+	}
+	v, err := json.MarshalIndent(o.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(o.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.path+".tmp", v, 0644); err != nil {
+		return err
+	}
+	return os.Rename(o.path+".tmp", o.path)
+}
+
+// uploadCacheKey hashes the parts of an upload that decide its result.
+func uploadCacheKey(kind string, parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return kind + ":" + hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package sydney
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sydneyqt/sydney/sydneytest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestUploadCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload_cache.json")
+	cache := NewUploadCache(path, time.Hour)
+	key := uploadCacheKey("image", []byte("image"))
+	assert.NotEqual(t, key, uploadCacheKey("image", []byte("imag"), []byte("e")))
+	_, ok := cache.get(key)
+	assert.False(t, ok)
+	cache.put(key, UploadCacheEntry{ImageURL: "https://example.com/blob"})
+	cache.put("expired", UploadCacheEntry{ImageURL: "https://example.com/expired"})
+	cache.entries["expired"] = UploadCacheEntry{ExpiresAt: time.Now().Add(-time.Minute)}
+	assert.Nil(t, cache.save())
+
+	cache = NewUploadCache(path, time.Hour) // reloaded from the file
+	entry, ok := cache.get(key)
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/blob", entry.ImageURL)
+	_, ok = cache.get("expired")
+	assert.False(t, ok)
+
+	assert.Nil(t, cache.Clear())
+	_, ok = NewUploadCache(path, time.Hour).get(key)
+	assert.False(t, ok)
+
+	var nilCache *UploadCache
+	nilCache.put(key, UploadCacheEntry{})
+	_, ok = nilCache.get(key)
+	assert.False(t, ok)
+}
+func TestAskStreamUploadCache(t *testing.T) {
+	syd, server := newFakeSydney(t)
+	syd.uploadFileURL = server.UploadFileURL()
+	syd.uploadCache = NewUploadCache(filepath.Join(t.TempDir(), "upload_cache.json"), time.Hour)
+	assert.Nil(t, os.WriteFile("spec.txt", []byte("spec"), 0644))
+	var statuses []string
+	for i := 0; i < 2; i++ {
+		server.Script(sydneytest.Text("Answer"), sydneytest.Finish())
+		messages, err := collectMessages(syd.AskStream(AskStreamOptions{
+			StopCtx:        context.Background(),
+			Prompt:         "summarize",
+			UploadFilePath: "spec.txt",
+			OnUploadProgress: func(progress UploadProgress) {
+				statuses = append(statuses, progress.Status)
+			},
+		}))
+		assert.Nil(t, err)
+		assert.Len(t, messages, 1)
+	}
+	assert.Equal(t, []string{UploadStatusUploading, UploadStatusUploaded, UploadStatusCached}, statuses)
+	assert.Equal(t, []string{"spec.txt"}, server.Uploads())
+	requests := server.Requests()
+	if assert.Len(t, requests, 2) {
+		query := `arguments.0.previousMessages.#(contextType=="ClientApp").hiddenText`
+		assert.Equal(t, gjson.Get(requests[0], query).String(), gjson.Get(requests[1], query).String())
+	}
+
+	// changed content is uploaded again
+	assert.Nil(t, os.WriteFile("spec.txt", []byte("spec v2"), 0644))
+	server.Script(sydneytest.Text("Answer"), sydneytest.Finish())
+	_, err := collectMessages(syd.AskStream(AskStreamOptions{
+		StopCtx:        context.Background(),
+		Prompt:         "summarize",
+		UploadFilePath: "spec.txt",
+	}))
+	assert.Nil(t, err)
+	assert.Len(t, server.Uploads(), 2)
+}
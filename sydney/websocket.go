@@ -1,6 +1,7 @@
 package sydney
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"nhooyr.io/websocket"
@@ -9,6 +10,12 @@ import (
 	"time"
 )
 
+const (
+	DefaultKeepaliveInterval = 5 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultIdleTimeout       = 3 * time.Minute
+)
+
 type Conn struct {
 	debug bool
 	*websocket.Conn
@@ -21,16 +28,28 @@ func (o *Conn) WriteWithTimeout(v []byte) error {
 	slog.Debug("WriteWithTimeout", "v", string(bytes))
 	return o.Write(ctx, websocket.MessageText, bytes)
 }
-func (o *Conn) ReadWithTimeout() ([]string, error) {
-	ctx, cancel := util.CreateTimeoutContext(30 * time.Second)
+
+// ReadWithTimeout reads the next frame. If no frame arrives within timeout, ErrStreamStalled is returned.
+// If ctx is done, its error is returned as is.
+// Note that the connection is closed once the read fails, as nhooyr.io/websocket does for cancelled reads.
+func (o *Conn) ReadWithTimeout(ctx context.Context, timeout time.Duration) ([]string, error) {
+	readCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	typ, v, err := o.Read(ctx)
+	typ, v, err := o.Read(readCtx)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(readCtx.Err(), context.DeadlineExceeded) {
+			return nil, classify(ErrStreamStalled,
+				errors.New("no message received from server in "+timeout.String()))
+		}
 		var closeErr websocket.CloseError
 		if errors.As(err, &closeErr) && closeErr.Code == websocket.StatusNormalClosure {
-			err = errors.Join(err, errors.New("please check if the chat context is too long"))
+			return nil, classify(ErrContextTooLong,
+				errors.Join(err, errors.New("please check if the chat context is too long")))
 		}
-		return nil, err
+		return nil, classify(ErrNetwork, err)
 	}
 	if typ != websocket.MessageText {
 		return nil, nil
@@ -45,3 +64,23 @@ func (o *Conn) ReadWithTimeout() ([]string, error) {
 	}
 	return arr, nil
 }
+
+// Keepalive sends a ping to the server every interval until ctx is done or the connection fails,
+// so that the server keeps the connection open while the answer is being generated.
+func (o *Conn) Keepalive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := o.WriteWithTimeout([]byte(`{"type": 6}`)); err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("Cannot send keepalive ping", "err", err)
+				}
+				return
+			}
+		}
+	}
+}
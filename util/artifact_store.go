@@ -0,0 +1,260 @@
+package util
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+// ArtifactFile is a chart or a file produced by the code interpreter. File is relative to the directory
+// of the artifact, and empty if it could not be downloaded.
+type ArtifactFile struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Kind string `json:"kind"` // image or file
+	File string `json:"file"`
+}
+
+// Artifact is the code run by the code interpreter in a turn of a workspace, along with its outputs.
+// CodeFile is relative to the directory of the artifact, and empty if there is no code.
+type Artifact struct {
+	ID          string         `json:"id"`
+	WorkspaceID int            `json:"workspace_id"`
+	Prompt      string         `json:"prompt"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Code        string         `json:"code"`
+	Language    string         `json:"language"`
+	CodeFile    string         `json:"code_file"`
+	Files       []ArtifactFile `json:"files"`
+}
+
+var codeFileExtensions = map[string]string{
+	"python":     "py",
+	"javascript": "js",
+	"typescript": "ts",
+	"shell":      "sh",
+	"bash":       "sh",
+	"ruby":       "rb",
+	"rust":       "rs",
+	"golang":     "go",
+	"kotlin":     "kt",
+	"markdown":   "md",
+}
+
+// codeFileExtension returns the file extension for code in the language, which is the language itself
+// for those like "go" or "java", and txt if it cannot be used as one.
+func codeFileExtension(language string) string {
+	if ext, ok := codeFileExtensions[language]; ok {
+		return ext
+	}
+	if language == "" || strings.ContainsFunc(language, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	}) {
+		return "txt"
+	}
+	return language
+}
+
+// ArtifactStore keeps code interpreter artifacts in a directory, one subdirectory per artifact containing
+// the code, the output files and an artifact.json sidecar, plus an index.json of all artifacts.
+type ArtifactStore struct {
+	dir   string
+	index *jsonIndex[Artifact]
+}
+
+func NewArtifactStore(dir string) *ArtifactStore {
+	return &ArtifactStore{
+		dir:   dir,
+		index: newJSONIndex(filepath.Join(dir, "index.json"), func(artifact Artifact) string { return artifact.ID }),
+	}
+}
+
+// Dir returns the directory of the store.
+func (o *ArtifactStore) Dir() string {
+	return o.dir
+}
+
+// ArtifactDir returns the directory where the code and the files of the artifact are saved.
+func (o *ArtifactStore) ArtifactDir(artifact Artifact) string {
+	return filepath.Join(o.dir, artifact.ID)
+}
+
+// Save writes the code of the artifact, downloads its files and adds it to the index. ID and CreatedAt
+// are filled if empty. Files that cannot be downloaded are kept with only their URLs.
+func (o *ArtifactStore) Save(ctx context.Context, proxy string, artifact Artifact) (Artifact, error) {
+	if artifact.Code == "" && len(artifact.Files) == 0 {
+		return Artifact{}, errors.New("the artifact has neither code nor files")
+	}
+	if artifact.ID == "" {
+		artifact.ID = uuid.New().String()
+	}
+	if artifact.CreatedAt.IsZero() {
+		artifact.CreatedAt = time.Now()
+	}
+	dir := o.ArtifactDir(artifact)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Artifact{}, err
+	}
+	var err error
+	if artifact.Code != "" {
+		artifact.CodeFile = "code." + codeFileExtension(artifact.Language)
+		err = os.WriteFile(filepath.Join(dir, artifact.CodeFile), []byte(artifact.Code), 0644)
+	}
+	if err == nil {
+		artifact.Files, err = o.download(ctx, proxy, artifact)
+	}
+	var v []byte
+	if err == nil {
+		v, err = json.MarshalIndent(artifact, "", "  ")
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "artifact.json"), v, 0644)
+	}
+	if err == nil {
+		err = o.index.add(artifact)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return Artifact{}, err
+	}
+	return artifact, nil
+}
+func (o *ArtifactStore) download(ctx context.Context, proxy string, artifact Artifact) ([]ArtifactFile, error) {
+	files := slices.Clone(artifact.Files)
+	used := map[string]bool{"artifact.json": true, artifact.CodeFile: true}
+	for i := range files {
+		data, err := Download(ctx, proxy, files[i].URL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		name := artifactFileName(files[i])
+		ext := path.Ext(name)
+		for n := 2; used[name]; n++ {
+			name = strings.TrimSuffix(artifactFileName(files[i]), ext) + "-" + strconv.Itoa(n) + ext
+		}
+		if err := os.WriteFile(filepath.Join(o.ArtifactDir(artifact), name), data, 0644); err != nil {
+			return nil, err
+		}
+		used[name] = true
+		files[i].File = name
+	}
+	return files, nil
+}
+
+// artifactFileName returns a file name safe to save the file as, without any directory.
+func artifactFileName(file ArtifactFile) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(file.Name))
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		name = "output"
+	}
+	if file.Kind == "image" && path.Ext(name) == "" {
+		name += ".png"
+	}
+	return name
+}
+
+// List returns the artifacts of the workspace, the newest first. A zero workspaceID lists all the artifacts.
+func (o *ArtifactStore) List(workspaceID int) ([]Artifact, error) {
+	artifacts, err := o.index.all()
+	if err != nil {
+		return nil, err
+	}
+	result := lo.Filter(artifacts, func(artifact Artifact, _ int) bool {
+		return workspaceID == 0 || artifact.WorkspaceID == workspaceID
+	})
+	slices.SortStableFunc(result, func(a, b Artifact) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return result, nil
+}
+
+// Get returns the artifact with the given id.
+func (o *ArtifactStore) Get(id string) (Artifact, error) {
+	artifact, ok, err := o.index.get(id)
+	if err == nil && !ok {
+		err = errors.New("artifact not found: " + id)
+	}
+	return artifact, err
+}
+
+// Delete removes the artifact from the index and deletes its directory.
+func (o *ArtifactStore) Delete(id string) error {
+	artifact, ok, err := o.index.remove(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("artifact not found: " + id)
+	}
+	return os.RemoveAll(o.ArtifactDir(artifact))
+}
+
+// Export copies the code and the downloaded files of the artifact into dir.
+func (o *ArtifactStore) Export(artifact Artifact, dir string) error {
+	for _, name := range artifactFiles(artifact) {
+		data, err := os.ReadFile(filepath.Join(o.ArtifactDir(artifact), name))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteZip writes the artifacts as a zip archive, each in a directory named after its creation time,
+// with its artifact.json sidecar.
+func (o *ArtifactStore) WriteZip(w io.Writer, artifacts []Artifact) error {
+	zw := zip.NewWriter(w)
+	for i, artifact := range artifacts {
+		dir := artifact.CreatedAt.Format("20060102-150405") + "-" + strconv.Itoa(i+1) + "/"
+		for _, name := range append(artifactFiles(artifact), "artifact.json") {
+			data, err := os.ReadFile(filepath.Join(o.ArtifactDir(artifact), name))
+			if err != nil {
+				return err
+			}
+			f, err := zw.CreateHeader(&zip.FileHeader{Name: dir + name, Method: zip.Deflate,
+				Modified: artifact.CreatedAt})
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// artifactFiles returns the names of the files saved for the artifact, except the sidecar.
+func artifactFiles(artifact Artifact) []string {
+	files := lo.FilterMap(artifact.Files, func(file ArtifactFile, _ int) (string, bool) {
+		return file.File, file.File != ""
+	})
+	if artifact.CodeFile != "" {
+		files = append([]string{artifact.CodeFile}, files...)
+	}
+	return files
+}
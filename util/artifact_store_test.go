@@ -0,0 +1,92 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifactStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("data of " + r.URL.Path))
+	}))
+	defer server.Close()
+	store := NewArtifactStore(t.TempDir())
+	ctx := context.Background()
+
+	plot, err := store.Save(ctx, "", Artifact{WorkspaceID: 1, Prompt: "Plot it", Code: "print(1)",
+		Language: "python", CreatedAt: time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC),
+		Files: []ArtifactFile{
+			{Name: "plot", URL: server.URL + "/plot", Kind: "image"},
+			{Name: "../data.csv", URL: server.URL + "/data", Kind: "file"},
+			{Name: "data.csv", URL: server.URL + "/data2", Kind: "file"},
+			{Name: "gone.txt", URL: server.URL + "/missing", Kind: "file"},
+		}})
+	assert.Nil(t, err)
+	assert.Equal(t, "code.py", plot.CodeFile)
+	assert.Equal(t, []string{"plot.png", "_data.csv", "data.csv", ""},
+		lo.Map(plot.Files, func(file ArtifactFile, _ int) string { return file.File }))
+	v, err := os.ReadFile(filepath.Join(store.ArtifactDir(plot), "plot.png"))
+	assert.Nil(t, err)
+	assert.Equal(t, "data of /plot", string(v))
+	assert.FileExists(t, filepath.Join(store.ArtifactDir(plot), "artifact.json"))
+
+	other, err := store.Save(ctx, "", Artifact{WorkspaceID: 2, Code: "fmt.Println(1)", Language: "go",
+		CreatedAt: time.Date(2024, 5, 21, 10, 0, 0, 0, time.UTC)})
+	assert.Nil(t, err)
+	assert.Equal(t, "code.go", other.CodeFile)
+	_, err = store.Save(ctx, "", Artifact{WorkspaceID: 1})
+	assert.NotNil(t, err)
+
+	artifacts, err := store.List(0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{other.ID, plot.ID}, lo.Map(artifacts, func(artifact Artifact, _ int) string {
+		return artifact.ID
+	}))
+	artifacts, err = store.List(1)
+	assert.Nil(t, err)
+	if assert.Len(t, artifacts, 1) {
+		assert.Equal(t, plot.ID, artifacts[0].ID)
+	}
+
+	dir := t.TempDir()
+	assert.Nil(t, store.Export(plot, dir))
+	assert.FileExists(t, filepath.Join(dir, "code.py"))
+	assert.FileExists(t, filepath.Join(dir, "_data.csv"))
+
+	var buf bytes.Buffer
+	assert.Nil(t, store.WriteZip(&buf, []Artifact{plot, other}))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"20240520-100000-1/code.py", "20240520-100000-1/plot.png", "20240520-100000-1/_data.csv",
+		"20240520-100000-1/data.csv", "20240520-100000-1/artifact.json",
+		"20240521-100000-2/code.go", "20240521-100000-2/artifact.json",
+	}, lo.Map(zr.File, func(f *zip.File, _ int) string { return f.Name }))
+	f, err := zr.Open("20240521-100000-2/code.go")
+	assert.Nil(t, err)
+	v, err = io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "fmt.Println(1)", string(v))
+
+	assert.Nil(t, store.Delete(plot.ID))
+	assert.NoDirExists(t, store.ArtifactDir(plot))
+	_, err = store.Get(plot.ID)
+	assert.NotNil(t, err)
+	_, err = store.Get(other.ID)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,229 @@
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// FileCookie is a cookie of cookies.json. The format is compatible with the JSON exported by
+// browser extensions like Cookie-Editor, so that such exports can be used as is.
+type FileCookie struct {
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Domain         string  `json:"domain,omitempty"`
+	Path           string  `json:"path,omitempty"`
+	ExpirationDate float64 `json:"expirationDate,omitempty"` // Unix seconds, 0 for session cookies.
+	HttpOnly       bool    `json:"httpOnly,omitempty"`
+	Secure         bool    `json:"secure,omitempty"`
+}
+
+// ExpiresAt returns the zero time for session cookies.
+func (o FileCookie) ExpiresAt() time.Time {
+	if o.ExpirationDate <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(o.ExpirationDate)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// AuthCookieNames are the cookies of a logged-in Bing account.
+var AuthCookieNames = []string{"_U", "KievRPSSecAuth"}
+
+// CookieExpiry tells when an auth cookie expires.
+type CookieExpiry struct {
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+}
+
+// ExpiringCookies returns the auth cookies expiring within d after now, including the expired ones.
+func ExpiringCookies(cookies []FileCookie, now time.Time, d time.Duration) []CookieExpiry {
+	var result []CookieExpiry
+	for _, cookie := range cookies {
+		expiresAt := cookie.ExpiresAt()
+		if !lo.Contains(AuthCookieNames, cookie.Name) || expiresAt.IsZero() || expiresAt.After(now.Add(d)) {
+			continue
+		}
+		result = append(result, CookieExpiry{
+			Name:      cookie.Name,
+			ExpiresAt: expiresAt,
+			Expired:   !expiresAt.After(now),
+		})
+	}
+	return result
+}
+
+// ParseCookieImport detects the format of text and parses the cookies of it. The formats supported are
+// the JSON exported by browser extensions or Playwright, the Netscape cookies.txt format,
+// and a Cookie header like "_U=xxx; SRCHHPGUSR=xxx".
+func ParseCookieImport(text string) ([]FileCookie, error) {
+	text = strings.TrimSpace(strings.TrimPrefix(text, "\ufeff"))
+	var cookies []FileCookie
+	var err error
+	switch {
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		cookies, err = parseJSONCookies(text)
+	case strings.HasPrefix(text, "# Netscape") || strings.HasPrefix(text, "# HTTP Cookie File") ||
+		strings.Contains(text, "\t"):
+		cookies, err = parseNetscapeCookies(text)
+	default:
+		cookies = parseCookieHeader(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 {
+		return nil, errors.New("no cookie found")
+	}
+	return cookies, nil
+}
+
+// jsonCookie covers the fields of the common JSON exports.
+type jsonCookie struct {
+	FileCookie
+	Expires *float64 `json:"expires"` // Playwright, -1 for session cookies
+	Session bool     `json:"session"`
+}
+
+func parseJSONCookies(text string) ([]FileCookie, error) {
+	var items []jsonCookie
+	if strings.HasPrefix(text, "{") { // Playwright storage state
+		var state struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal([]byte(text), &state); err != nil {
+			return nil, fmt.Errorf("cannot parse cookies in json: %w", err)
+		}
+		items = state.Cookies
+	} else if err := json.Unmarshal([]byte(text), &items); err != nil {
+		return nil, fmt.Errorf("cannot parse cookies in json: %w", err)
+	}
+	return lo.FilterMap(items, func(item jsonCookie, _ int) (FileCookie, bool) {
+		cookie := item.FileCookie
+		if item.Expires != nil && *item.Expires > 0 {
+			cookie.ExpirationDate = *item.Expires
+		}
+		if item.Session {
+			cookie.ExpirationDate = 0
+		}
+		return cookie, cookie.Name != ""
+	}), nil
+}
+
+// parseNetscapeCookies parses lines of domain, include subdomains, path, secure, expiry, name and value
+// separated by tabs. Lines starting with #HttpOnly_ are HttpOnly cookies, and other # lines are comments.
+func parseNetscapeCookies(text string) ([]FileCookie, error) {
+	var cookies []FileCookie
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(nil, 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("invalid cookies.txt line %d: %d fields instead of 7", lineNo, len(fields))
+		}
+		expiry, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookies.txt line %d: %w", lineNo, err)
+		}
+		cookies = append(cookies, FileCookie{
+			Name:           fields[5],
+			Value:          strings.Join(fields[6:], "\t"),
+			Domain:         fields[0],
+			Path:           fields[2],
+			ExpirationDate: max(expiry, 0),
+			HttpOnly:       httpOnly,
+			Secure:         strings.EqualFold(fields[3], "TRUE"),
+		})
+	}
+	return cookies, scanner.Err()
+}
+func parseCookieHeader(text string) []FileCookie {
+	if name, value, ok := strings.Cut(text, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "cookie") {
+		text = value
+	}
+	cookies := lo.MapToSlice(ParseCookiesFromString(text), func(name string, value string) FileCookie {
+		return FileCookie{Name: name, Value: value}
+	})
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].Name < cookies[j].Name
+	})
+	return cookies
+}
+
+// FilterCookiesByDomain keeps the cookies of domain and its subdomains, and those without a domain.
+func FilterCookiesByDomain(cookies []FileCookie, domain string) []FileCookie {
+	return lo.Filter(cookies, func(cookie FileCookie, _ int) bool {
+		d := strings.TrimPrefix(cookie.Domain, ".")
+		return d == "" || d == domain || strings.HasSuffix(d, "."+domain)
+	})
+}
+
+func ReadCookiesFileRaw() ([]FileCookie, error) {
+	v, err := os.ReadFile(WithPath("cookies.json"))
+	if err != nil {
+		return nil, nil
+	}
+	var cookies []FileCookie
+	err = json.Unmarshal(v, &cookies)
+	if err != nil {
+		return nil, errors.New("failed to json.Unmarshal content of cookie file")
+	}
+	return cookies, nil
+}
+func ReadCookiesFile() (map[string]string, error) {
+	res := map[string]string{}
+	cookies, err := ReadCookiesFileRaw()
+	if err != nil {
+		return nil, err
+	}
+	for _, cookie := range cookies {
+		res[cookie.Name] = cookie.Value
+	}
+	return res, nil
+}
+func WriteCookiesFile(cookies []FileCookie) error {
+	v, err := json.MarshalIndent(&cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(WithPath("cookies.json"), v, 0644)
+}
+
+// UpdateCookiesFile sets the values of cookies in the cookie file, keeping the metadata of existing ones.
+// The expiration date of a cookie whose value changes is cleared, as it belongs to the old value.
+func UpdateCookiesFile(cookies map[string]string) error {
+	arr, err := ReadCookiesFileRaw()
+	if err != nil {
+		return err
+	}
+	for i, cookie := range arr {
+		if value, ok := cookies[cookie.Name]; ok && value != cookie.Value {
+			arr[i].Value = value
+			arr[i].ExpirationDate = 0
+		}
+	}
+	names := lo.Keys(cookies)
+	sort.Strings(names)
+	for _, name := range names {
+		if !lo.ContainsBy(arr, func(cookie FileCookie) bool { return cookie.Name == name }) {
+			arr = append(arr, FileCookie{Name: name, Value: cookies[name]})
+		}
+	}
+	return WriteCookiesFile(arr)
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCookieImport(t *testing.T) {
+	t.Run("extension json", func(t *testing.T) {
+		cookies, err := ParseCookieImport(`[{"domain": ".bing.com", "expirationDate": 1767225600.5,
+			"hostOnly": false, "httpOnly": true, "name": "_U", "path": "/", "sameSite": "no_restriction",
+			"secure": true, "session": false, "storeId": "0", "value": "abc"},
+			{"domain": "www.bing.com", "name": "SRCHHPGUSR", "session": true, "value": "def"}]`)
+		assert.Nil(t, err)
+		assert.Equal(t, []FileCookie{
+			{Name: "_U", Value: "abc", Domain: ".bing.com", Path: "/", ExpirationDate: 1767225600.5,
+				HttpOnly: true, Secure: true},
+			{Name: "SRCHHPGUSR", Value: "def", Domain: "www.bing.com"},
+		}, cookies)
+	})
+	t.Run("playwright", func(t *testing.T) {
+		cookies, err := ParseCookieImport(`{"cookies": [{"name": "_U", "value": "abc", "domain": ".bing.com",
+			"path": "/", "expires": 1767225600, "httpOnly": true, "secure": true},
+			{"name": "MUID", "value": "def", "domain": ".bing.com", "path": "/", "expires": -1}], "origins": []}`)
+		assert.Nil(t, err)
+		if assert.Len(t, cookies, 2) {
+			assert.Equal(t, time.Unix(1767225600, 0), cookies[0].ExpiresAt())
+			assert.True(t, cookies[1].ExpiresAt().IsZero())
+		}
+	})
+	t.Run("netscape", func(t *testing.T) {
+		cookies, err := ParseCookieImport("# Netscape HTTP Cookie File\n\n" +
+			"#HttpOnly_.bing.com\tTRUE\t/\tTRUE\t1767225600\t_U\tabc\n" +
+			".bing.com\tTRUE\t/\tFALSE\t0\tSRCHHPGUSR\tdef=1\r\n")
+		assert.Nil(t, err)
+		assert.Equal(t, []FileCookie{
+			{Name: "_U", Value: "abc", Domain: ".bing.com", Path: "/", ExpirationDate: 1767225600,
+				HttpOnly: true, Secure: true},
+			{Name: "SRCHHPGUSR", Value: "def=1", Domain: ".bing.com", Path: "/"},
+		}, cookies)
+		_, err = ParseCookieImport(".bing.com\tTRUE\t/\n")
+		assert.NotNil(t, err)
+	})
+	t.Run("header", func(t *testing.T) {
+		cookies, err := ParseCookieImport("Cookie: _U=abc; SRCHHPGUSR=def=1")
+		assert.Nil(t, err)
+		assert.Equal(t, []FileCookie{{Name: "SRCHHPGUSR", Value: "def=1"}, {Name: "_U", Value: "abc"}}, cookies)
+	})
+	t.Run("empty", func(t *testing.T) {
+		_, err := ParseCookieImport("[]")
+		assert.NotNil(t, err)
+	})
+}
+func TestFilterCookiesByDomain(t *testing.T) {
+	cookies := FilterCookiesByDomain([]FileCookie{
+		{Name: "a", Domain: ".bing.com"},
+		{Name: "b", Domain: "www.bing.com"},
+		{Name: "c"},
+		{Name: "d", Domain: "notbing.com"},
+		{Name: "e", Domain: ".google.com"},
+	}, "bing.com")
+	assert.Equal(t, []string{"a", "b", "c"}, Map(cookies, func(cookie FileCookie) string { return cookie.Name }))
+}
+func TestExpiringCookies(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expiry := ExpiringCookies([]FileCookie{
+		{Name: "_U", ExpirationDate: 1700000000 + 3600},
+		{Name: "KievRPSSecAuth", ExpirationDate: 1700000000 - 1},
+		{Name: "MUID", ExpirationDate: 1700000000 - 1}, // not an auth cookie
+	}, now, 24*time.Hour)
+	assert.Equal(t, []CookieExpiry{
+		{Name: "_U", ExpiresAt: time.Unix(1700003600, 0)},
+		{Name: "KievRPSSecAuth", ExpiresAt: time.Unix(1699999999, 0), Expired: true},
+	}, expiry)
+	assert.Empty(t, ExpiringCookies([]FileCookie{{Name: "_U"}}, now, 24*time.Hour))
+}
+func TestUpdateCookiesFile(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	assert.Nil(t, WriteCookiesFile([]FileCookie{
+		{Name: "_U", Value: "abc", Domain: ".bing.com", ExpirationDate: 1},
+		{Name: "MUID", Value: "same", Domain: ".bing.com", ExpirationDate: 2},
+	}))
+	assert.Nil(t, UpdateCookiesFile(map[string]string{"_U": "new", "MUID": "same", "cct": "def"}))
+	cookies, err := ReadCookiesFileRaw()
+	assert.Nil(t, err)
+	assert.Equal(t, []FileCookie{
+		{Name: "_U", Value: "new", Domain: ".bing.com"},
+		{Name: "MUID", Value: "same", Domain: ".bing.com", ExpirationDate: 2},
+		{Name: "cct", Value: "def"},
+	}, cookies)
+}
@@ -3,15 +3,21 @@ package util
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"github.com/klippa-app/go-pdfium"
 	"github.com/klippa-app/go-pdfium/requests"
 	"github.com/klippa-app/go-pdfium/webassembly"
 	"github.com/microcosm-cc/bluemonday"
+	"github.com/samber/lo"
+	"html"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"regexp"
 	"slices"
 	"strconv"
@@ -204,3 +210,281 @@ func (p PlainDocumentReader) Read(filePath string) (string, error) {
 func (p PlainDocumentReader) WillSkipPostprocess() bool {
 	return false
 }
+
+type OdtDocumentReader struct {
+	MaxEntrySize int // of each file of the archive once decompressed, DefaultZipEntryMaxSize if 0
+}
+
+func (o OdtDocumentReader) WillSkipPostprocess() bool {
+	return false
+}
+
+func (o OdtDocumentReader) Read(filePath string) (string, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	v, err := readZipFile(&reader.Reader, "content.xml", o.MaxEntrySize)
+	if err != nil {
+		return "", err
+	}
+	text := string(v)
+	for _, tag := range []string{"<text:p", "<text:h", "<text:line-break", "<text:list-item"} {
+		text = strings.ReplaceAll(text, tag, "\n"+tag)
+	}
+	return html.UnescapeString(bluemonday.StripTagsPolicy().Sanitize(text)), nil
+}
+
+type HTMLDocumentReader struct {
+}
+
+func (h HTMLDocumentReader) WillSkipPostprocess() bool {
+	return false
+}
+
+func (h HTMLDocumentReader) Read(filePath string) (string, error) {
+	v, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return htmlToText(string(v)), nil
+}
+
+var htmlInvisibleRegs = lo.Map([]string{"head", "script", "style", "noscript", "template"},
+	func(tag string, index int) *regexp.Regexp {
+		return regexp.MustCompile("(?is)<" + tag + "\\b.*?</" + tag + ">")
+	})
+var htmlBlockReg = regexp.MustCompile("(?i)<(p|div|br|li|tr|h[1-6]|section|article|blockquote|pre)\\b")
+
+// htmlToText strips the tags of an HTML page, keeping the line breaks of block elements.
+func htmlToText(s string) string {
+	for _, reg := range htmlInvisibleRegs {
+		s = reg.ReplaceAllString(s, "")
+	}
+	s = htmlBlockReg.ReplaceAllString(s, "\n$0")
+	return html.UnescapeString(bluemonday.StripTagsPolicy().Sanitize(s))
+}
+
+type EpubDocumentReader struct {
+	MaxEntrySize int // of each file of the archive once decompressed, DefaultZipEntryMaxSize if 0
+}
+
+type epubItem struct {
+	ID   string `xml:"id,attr"`
+	Href string `xml:"href,attr"`
+}
+
+func (e EpubDocumentReader) WillSkipPostprocess() bool {
+	return false
+}
+
+func (e EpubDocumentReader) Read(filePath string) (string, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	v, err := readZipFile(&reader.Reader, "META-INF/container.xml", e.MaxEntrySize)
+	if err != nil {
+		return "", err
+	}
+	var container struct {
+		RootFiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err = xml.Unmarshal(v, &container); err != nil {
+		return "", fmt.Errorf("cannot parse container.xml: %w", err)
+	}
+	if len(container.RootFiles) == 0 {
+		return "", errors.New("no rootfile in container.xml")
+	}
+	opfPath := container.RootFiles[0].FullPath
+	v, err = readZipFile(&reader.Reader, opfPath, e.MaxEntrySize)
+	if err != nil {
+		return "", err
+	}
+	var opf struct {
+		Items    []epubItem `xml:"manifest>item"`
+		ItemRefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err = xml.Unmarshal(v, &opf); err != nil {
+		return "", fmt.Errorf("cannot parse %s: %w", opfPath, err)
+	}
+	var buf strings.Builder
+	for _, itemRef := range opf.ItemRefs {
+		item, ok := lo.Find(opf.Items, func(item epubItem) bool {
+			return item.ID == itemRef.IDRef
+		})
+		if !ok {
+			continue
+		}
+		v, err := readZipFile(&reader.Reader, path.Join(path.Dir(opfPath), item.Href), e.MaxEntrySize)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(htmlToText(string(v)) + "\n")
+	}
+	return buf.String(), nil
+}
+
+type XlsxDocumentReader struct {
+	MaxEntrySize int // of each file of the archive once decompressed, DefaultZipEntryMaxSize if 0
+}
+
+func (x XlsxDocumentReader) WillSkipPostprocess() bool {
+	return true
+}
+
+type xlsxText struct {
+	T string        `xml:"t"`
+	R []xlsxTextRun `xml:"r"`
+}
+type xlsxTextRun struct {
+	T string `xml:"t"`
+}
+type xlsxRelationship struct {
+	ID     string `xml:"Id,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+func (t xlsxText) String() string {
+	return t.T + strings.Join(lo.Map(t.R, func(r xlsxTextRun, index int) string {
+		return r.T
+	}), "")
+}
+
+// Read converts the sheets of the workbook to CSV. Sheets after the first one are separated by
+// an empty line and a line of their names.
+func (x XlsxDocumentReader) Read(filePath string) (string, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var relationships struct {
+		Items []xlsxRelationship `xml:"Relationship"`
+	}
+	var sharedStrings struct {
+		Items []xlsxText `xml:"si"`
+	}
+	for name, v := range map[string]any{
+		"xl/workbook.xml":            &workbook,
+		"xl/_rels/workbook.xml.rels": &relationships,
+		"xl/sharedStrings.xml":       &sharedStrings,
+	} {
+		data, err := readZipFile(&reader.Reader, name, x.MaxEntrySize)
+		if errors.Is(err, fs.ErrNotExist) && name == "xl/sharedStrings.xml" {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err = xml.Unmarshal(data, v); err != nil {
+			return "", fmt.Errorf("cannot parse %s: %w", name, err)
+		}
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for i, sheet := range workbook.Sheets {
+		relationship, ok := lo.Find(relationships.Items, func(item xlsxRelationship) bool {
+			return item.ID == sheet.RID
+		})
+		if !ok {
+			return "", errors.New("cannot find sheet " + sheet.Name)
+		}
+		sheetPath := lo.Ternary(strings.HasPrefix(relationship.Target, "/"),
+			strings.TrimPrefix(relationship.Target, "/"), path.Join("xl", relationship.Target))
+		data, err := readZipFile(&reader.Reader, sheetPath, x.MaxEntrySize)
+		if err != nil {
+			return "", err
+		}
+		var worksheet struct {
+			Rows []struct {
+				Cells []struct {
+					Ref    string   `xml:"r,attr"`
+					Type   string   `xml:"t,attr"`
+					Value  string   `xml:"v"`
+					Inline xlsxText `xml:"is"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		if err = xml.Unmarshal(data, &worksheet); err != nil {
+			return "", fmt.Errorf("cannot parse %s: %w", sheetPath, err)
+		}
+		if i != 0 {
+			w.Write(nil)
+			w.Write([]string{sheet.Name})
+		}
+		for _, row := range worksheet.Rows {
+			var record []string
+			for _, cell := range row.Cells {
+				column := xlsxColumn(cell.Ref)
+				if column < 0 { // the reference is optional
+					column = len(record)
+				}
+				if column >= len(record) {
+					record = append(record, make([]string, column-len(record)+1)...)
+				}
+				value := cell.Value
+				switch cell.Type {
+				case "s":
+					if index, err := strconv.Atoi(cell.Value); err == nil && index < len(sharedStrings.Items) {
+						value = sharedStrings.Items[index].String()
+					}
+				case "inlineStr":
+					value = cell.Inline.String()
+				}
+				record[column] = value
+			}
+			w.Write(record)
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// xlsxColumn returns the zero-based column of a cell reference like AB12.
+func xlsxColumn(ref string) int {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+	}
+	return column - 1
+}
+
+// DefaultZipEntryMaxSize is the limit of each file read from an archive by the readers of zip-based formats.
+const DefaultZipEntryMaxSize = 64 << 20
+
+// readZipFile reads a file of the archive, failing if it is larger than maxSize once decompressed,
+// or DefaultZipEntryMaxSize if maxSize is 0, so that a small archive cannot inflate into huge memory.
+func readZipFile(reader *zip.Reader, name string, maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultZipEntryMaxSize
+	}
+	f, err := reader.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes once decompressed", name, maxSize)
+	}
+	return data, nil
+}
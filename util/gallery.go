@@ -0,0 +1,119 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GalleryImage is a generated image saved in a Gallery.
+type GalleryImage struct {
+	ID          string        `json:"id"`
+	Prompt      string        `json:"prompt"`
+	WorkspaceID int           `json:"workspace_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	SourceURL   string        `json:"source_url"`
+	Duration    time.Duration `json:"duration"` // of the generation
+	FileName    string        `json:"file_name"`
+}
+
+// Gallery keeps generated images in a directory with an index.json describing them.
+type Gallery struct {
+	dir   string
+	index *jsonIndex[GalleryImage]
+}
+
+func NewGallery(dir string) *Gallery {
+	return &Gallery{
+		dir:   dir,
+		index: newJSONIndex(filepath.Join(dir, "index.json"), func(image GalleryImage) string { return image.ID }),
+	}
+}
+
+// Dir returns the directory where the images are saved.
+func (o *Gallery) Dir() string {
+	return o.dir
+}
+
+// Path returns the full path of the image file.
+func (o *Gallery) Path(image GalleryImage) string {
+	return filepath.Join(o.dir, image.FileName)
+}
+
+// Add saves the image file and appends it to the index. ID, CreatedAt and FileName are filled if empty.
+func (o *Gallery) Add(image GalleryImage, data []byte) (GalleryImage, error) {
+	if image.ID == "" {
+		image.ID = uuid.New().String()
+	}
+	if image.CreatedAt.IsZero() {
+		image.CreatedAt = time.Now()
+	}
+	if image.FileName == "" {
+		image.FileName = image.ID + ".jpg"
+	}
+	if err := os.MkdirAll(o.dir, 0755); err != nil {
+		return GalleryImage{}, err
+	}
+	if err := os.WriteFile(o.Path(image), data, 0644); err != nil {
+		return GalleryImage{}, err
+	}
+	if err := o.index.add(image); err != nil {
+		_ = os.Remove(o.Path(image))
+		return GalleryImage{}, err
+	}
+	return image, nil
+}
+
+// List returns all images, the newest first.
+func (o *Gallery) List() ([]GalleryImage, error) {
+	return o.Search("")
+}
+
+// Search returns the images whose prompts contain every word of query case-insensitively, the newest first.
+func (o *Gallery) Search(query string) ([]GalleryImage, error) {
+	images, err := o.index.all()
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(query))
+	result := []GalleryImage{}
+	for _, image := range images {
+		prompt := strings.ToLower(image.Prompt)
+		if !slices.ContainsFunc(words, func(word string) bool { return !strings.Contains(prompt, word) }) {
+			result = append(result, image)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b GalleryImage) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return result, nil
+}
+
+// Get returns the image with the given id.
+func (o *Gallery) Get(id string) (GalleryImage, error) {
+	image, ok, err := o.index.get(id)
+	if err == nil && !ok {
+		err = errors.New("image not found in the gallery: " + id)
+	}
+	return image, err
+}
+
+// Delete removes the image from the index and deletes its file.
+func (o *Gallery) Delete(id string) error {
+	image, ok, err := o.index.remove(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("image not found in the gallery: " + id)
+	}
+	if err := os.Remove(o.Path(image)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
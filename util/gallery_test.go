@@ -0,0 +1,49 @@
+package util
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGallery(t *testing.T) {
+	gallery := NewGallery(t.TempDir())
+	now := time.Now()
+	cat, err := gallery.Add(GalleryImage{Prompt: "A cat in space", WorkspaceID: 1, CreatedAt: now.Add(-time.Hour),
+		SourceURL: "https://example.com/cat"}, []byte("cat"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, cat.ID)
+	dog, err := gallery.Add(GalleryImage{Prompt: "A dog on the moon", WorkspaceID: 2}, []byte("dog"))
+	assert.Nil(t, err)
+	v, err := os.ReadFile(gallery.Path(dog))
+	assert.Nil(t, err)
+	assert.Equal(t, "dog", string(v))
+
+	images, err := gallery.List()
+	assert.Nil(t, err)
+	if assert.Len(t, images, 2) {
+		assert.Equal(t, dog.ID, images[0].ID)
+		assert.Equal(t, cat.SourceURL, images[1].SourceURL)
+		assert.True(t, cat.CreatedAt.Equal(images[1].CreatedAt))
+	}
+	images, err = gallery.Search("SPACE cat")
+	assert.Nil(t, err)
+	if assert.Len(t, images, 1) {
+		assert.Equal(t, cat.ID, images[0].ID)
+	}
+	images, err = gallery.Search("cat moon")
+	assert.Nil(t, err)
+	assert.Empty(t, images)
+
+	assert.Nil(t, gallery.Delete(cat.ID))
+	assert.NotNil(t, gallery.Delete(cat.ID))
+	_, err = gallery.Get(cat.ID)
+	assert.NotNil(t, err)
+	_, err = os.Stat(gallery.Path(cat))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	image, err := gallery.Get(dog.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, image.WorkspaceID)
+}
@@ -0,0 +1,120 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3"
+	getproxy "github.com/rapid7/go-get-proxied/proxy"
+)
+
+type httpClientKey struct {
+	proxy   string
+	timeout time.Duration
+}
+type httpClients struct {
+	client    *http.Client
+	reqClient *req.Client
+}
+
+var (
+	httpClientMu    sync.Mutex
+	httpClientCache = map[httpClientKey]httpClients{}
+	// systemProxy is detected once and then cached until ResetHTTPClients. nil means no system proxy.
+	systemProxy         *url.URL
+	systemProxyDetected bool
+)
+
+// MakeHTTPClient returns the clients using proxy, or the system proxy if empty, and timeout, 0 for no timeout.
+// The clients are cached and shared by all callers with the same arguments, so that connections are reused.
+// Therefore, they must not be modified: set headers per request, or Clone the req client instead.
+// Neither of them keeps cookies received.
+func MakeHTTPClient(proxy string, timeout time.Duration) (*http.Client, *req.Client, error) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	key := httpClientKey{proxy: proxy, timeout: timeout}
+	if clients, ok := httpClientCache[key]; ok {
+		return clients.client, clients.reqClient, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	reqClient := req.C().ImpersonateChrome().SetCommonHeader("accept-language", "en-US,en;q=0.9").
+		SetCookieJar(nil)
+	proxyURL := detectSystemProxy()
+	if proxy != "" { // user filled proxy
+		var err error
+		proxyURL, err = url.Parse(proxy)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+		reqClient.SetProxyURL(proxyURL.String())
+	}
+	client := &http.Client{}
+	client.Transport = transport
+	if timeout != time.Duration(0) {
+		client.Timeout = timeout
+		reqClient.SetTimeout(timeout)
+	}
+	httpClientCache[key] = httpClients{client: client, reqClient: reqClient}
+	return client, reqClient, nil
+}
+
+// Download fetches the body of url, failing on error status codes.
+func Download(ctx context.Context, proxy string, url string) ([]byte, error) {
+	_, client, err := MakeHTTPClient(proxy, 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.R().SetContext(ctx).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsErrorState() {
+		return nil, errors.New("cannot download " + url + ": " + resp.GetStatus())
+	}
+	return resp.Bytes(), nil
+}
+
+// ResetHTTPClients drops the cached clients and detects the system proxy again on the next MakeHTTPClient,
+// e.g. after the proxy setting has changed. Clients in use keep working.
+func ResetHTTPClients() {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	for _, clients := range httpClientCache {
+		clients.client.CloseIdleConnections()
+		clients.reqClient.GetTransport().CloseIdleConnections()
+	}
+	httpClientCache = map[httpClientKey]httpClients{}
+	systemProxy = nil
+	systemProxyDetected = false
+}
+
+// detectSystemProxy must be called with httpClientMu held.
+func detectSystemProxy() *url.URL {
+	if systemProxyDetected {
+		return systemProxy
+	}
+	// getproxy reports the proxies it cannot find to the standard logger, which is left alone as other
+	// goroutines log meanwhile. It only happens once per ResetHTTPClients.
+	proxies := []getproxy.Proxy{
+		getproxy.NewProvider("").GetHTTPProxy("https://www.bing.com"),
+		getproxy.NewProvider("").GetHTTPSProxy("https://www.bing.com"),
+		getproxy.NewProvider("").GetSOCKSProxy("https://www.bing.com"),
+	}
+	for _, p := range proxies {
+		if p != nil { // valid system proxy
+			systemProxy = p.URL()
+			break
+		}
+	}
+	systemProxyDetected = true
+	slog.Info("Detected system proxy", "proxy", systemProxy)
+	return systemProxy
+}
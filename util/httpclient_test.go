@@ -0,0 +1,36 @@
+package util
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeHTTPClient(t *testing.T) {
+	t.Cleanup(ResetHTTPClients)
+	client, reqClient, err := MakeHTTPClient("http://127.0.0.1:7890", 15*time.Second)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client2, reqClient2, err := MakeHTTPClient("http://127.0.0.1:7890", 15*time.Second)
+			assert.Nil(t, err)
+			assert.Same(t, client, client2)
+			assert.Same(t, reqClient, reqClient2)
+		}()
+	}
+	wg.Wait()
+	_, reqClient3, err := MakeHTTPClient("http://127.0.0.1:7890", 30*time.Second)
+	assert.Nil(t, err)
+	assert.NotSame(t, reqClient, reqClient3)
+	_, _, err = MakeHTTPClient("://invalid", 0)
+	assert.NotNil(t, err)
+	ResetHTTPClients()
+	_, reqClient4, err := MakeHTTPClient("http://127.0.0.1:7890", 15*time.Second)
+	assert.Nil(t, err)
+	assert.NotSame(t, reqClient, reqClient4)
+}
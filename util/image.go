@@ -0,0 +1,216 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrInvalidImage  = errors.New("invalid image")
+	ErrImageTooLarge = errors.New("image too large")
+)
+
+// ImageOptions controls how PrepareImage converts an image before uploading it.
+type ImageOptions struct {
+	MaxDimension int // of the longer side in pixels, 0 for unlimited
+	MaxBytes     int // of the encoded JPEG, 0 for unlimited
+	Quality      int // JPEG quality to start with
+	MinQuality   int // the quality is lowered down to it before the image is shrunk further to fit MaxBytes
+	MaxPixels    int // of the image to decode, 0 for unlimited; larger ones are rejected before being decoded
+}
+
+var DefaultImageOptions = ImageOptions{
+	MaxDimension: 2048,
+	MaxBytes:     1 << 20,
+	Quality:      85,
+	MinQuality:   45,
+	MaxPixels:    64 << 20,
+}
+
+// PrepareImage decodes a GIF, JPEG, PNG, WebP, BMP or TIFF image and re-encodes it as a JPEG for Bing.
+// The image is rotated according to its EXIF orientation, transparent pixels become white, and it is
+// downscaled to options.MaxDimension. Then the quality is lowered, and the image shrunk if still needed,
+// until it fits options.MaxBytes. Metadata like EXIF is not kept. Images of more than options.MaxPixels
+// pixels are rejected by their header, so that a small file cannot take up huge memory once decoded.
+// Images that cannot be decoded are reported with ErrInvalidImage, and those over the limits with ErrImageTooLarge.
+func PrepareImage(data []byte, options ImageOptions) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	if options.MaxPixels > 0 && config.Width*config.Height > options.MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels, the limit is %d pixels",
+			ErrImageTooLarge, config.Width, config.Height, options.MaxPixels)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	if options.Quality <= 0 {
+		options.Quality = DefaultImageOptions.Quality
+	}
+	options.MinQuality = min(max(options.MinQuality, 1), options.Quality)
+	img := orientImage(fitImage(src, options.MaxDimension), jpegOrientation(data))
+	quality := options.Quality
+	for {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		if options.MaxBytes <= 0 || buf.Len() <= options.MaxBytes {
+			return buf.Bytes(), nil
+		}
+		if quality > options.MinQuality {
+			quality = max(quality-10, options.MinQuality)
+			continue
+		}
+		longer := max(img.Bounds().Dx(), img.Bounds().Dy())
+		if longer <= 64 {
+			return nil, fmt.Errorf("%w: cannot compress it to fit the size limit", ErrImageTooLarge)
+		}
+		img = fitImage(img, longer*3/4)
+		quality = options.Quality
+	}
+}
+
+// DecodeBase64Image decodes a data URL like data:image/png;base64,xxx or a plain base64 string.
+// Malformed input is reported with ErrInvalidImage.
+func DecodeBase64Image(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "data:") {
+		header, payload, ok := strings.Cut(s, ",")
+		if !ok || !strings.HasSuffix(header, ";base64") {
+			return nil, fmt.Errorf("%w: data url is not base64 encoded", ErrInvalidImage)
+		}
+		s = payload
+	}
+	s = strings.TrimRight(s, "=")
+	encoding := Ternary(strings.ContainsAny(s, "-_"), base64.RawURLEncoding, base64.RawStdEncoding)
+	data, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	return data, nil
+}
+
+// fitImage draws src on a white background, downscaled so that its longer side is at most maxDimension.
+func fitImage(src image.Image, maxDimension int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if longer := max(w, h); maxDimension > 0 && longer > maxDimension {
+		w, h = max(w*maxDimension/longer, 1), max(h*maxDimension/longer, 1)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	if w == b.Dx() && h == b.Dy() {
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	}
+	return dst
+}
+
+// orientImage transforms the image to be displayed upright according to an EXIF orientation from 1 to 8.
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // rotated by 90 degrees
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		for dx := 0; dx < dw; dx++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-dx, dy
+			case 3: // rotated by 180 degrees
+				sx, sy = w-1-dx, h-1-dy
+			case 4: // mirrored vertically
+				sx, sy = dx, h-1-dy
+			case 5: // transposed
+				sx, sy = dy, dx
+			case 6: // to be rotated by 90 degrees clockwise
+				sx, sy = dy, h-1-dx
+			case 7: // transversed
+				sx, sy = w-1-dy, h-1-dx
+			case 8: // to be rotated by 90 degrees counterclockwise
+				sx, sy = w-1-dy, dx
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG image, or 1 if absent.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // image data starts
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		if segment := data[i+4 : end]; marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
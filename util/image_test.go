@@ -0,0 +1,123 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func decodeJpeg(t *testing.T, data []byte) image.Image {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	assert.Nil(t, err)
+	return img
+}
+func assertColor(t *testing.T, expected color.RGBA, actual color.Color) {
+	r, g, b, _ := actual.RGBA()
+	assert.InDelta(t, expected.R, r>>8, 24)
+	assert.InDelta(t, expected.G, g>>8, 24)
+	assert.InDelta(t, expected.B, b>>8, 24)
+}
+
+func TestPrepareImageDownscale(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4000, 1000)) // transparent
+	for x := 2000; x < 4000; x++ {
+		for y := 0; y < 1000; y++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, img))
+	data, err := PrepareImage(buf.Bytes(), DefaultImageOptions)
+	assert.Nil(t, err)
+	result := decodeJpeg(t, data)
+	assert.Equal(t, image.Rect(0, 0, 2048, 512), result.Bounds())
+	assertColor(t, color.RGBA{R: 255, G: 255, B: 255}, result.At(10, 10))
+	assertColor(t, color.RGBA{R: 255}, result.At(2000, 10))
+}
+func TestPrepareImageFormats(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	for name, encode := range map[string]func(*bytes.Buffer) error{
+		"bmp":  func(buf *bytes.Buffer) error { return bmp.Encode(buf, img) },
+		"tiff": func(buf *bytes.Buffer) error { return tiff.Encode(buf, img, nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Nil(t, encode(&buf))
+			data, err := PrepareImage(buf.Bytes(), DefaultImageOptions)
+			assert.Nil(t, err)
+			assert.Equal(t, image.Rect(0, 0, 30, 20), decodeJpeg(t, data).Bounds())
+		})
+	}
+	_, err := PrepareImage([]byte("not an image"), DefaultImageOptions)
+	assert.ErrorIs(t, err, ErrInvalidImage)
+}
+func TestPrepareImageOrientation(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, Ternary(x < 20, color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}))
+		}
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&buf, img, nil))
+	// APP1 segment with a little-endian TIFF header and a single IFD0 entry: orientation (0x0112) = 6
+	exif := []byte("Exif\x00\x00II\x2a\x00\x08\x00\x00\x00\x01\x00\x12\x01\x03\x00\x01\x00\x00\x00\x06\x00\x00\x00\x00\x00\x00\x00")
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	data := append(append(append([]byte{}, buf.Bytes()[:2]...), segment...), buf.Bytes()[2:]...)
+	assert.Equal(t, 6, jpegOrientation(data))
+
+	data, err := PrepareImage(data, DefaultImageOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, jpegOrientation(data))
+	result := decodeJpeg(t, data)
+	assert.Equal(t, image.Rect(0, 0, 20, 40), result.Bounds())
+	assertColor(t, color.RGBA{R: 255}, result.At(10, 5))
+	assertColor(t, color.RGBA{B: 255}, result.At(10, 35))
+}
+func TestPrepareImageMaxBytes(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 1000))
+	random := rand.New(rand.NewSource(1))
+	random.Read(img.Pix)
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, img))
+	options := DefaultImageOptions
+	options.MaxBytes = 50 * 1024
+	data, err := PrepareImage(buf.Bytes(), options)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, len(data), options.MaxBytes)
+	assert.Less(t, decodeJpeg(t, data).Bounds().Dx(), 1000)
+}
+func TestDecodeBase64Image(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("image?"))
+	for _, s := range []string{encoded, "data:image/png;base64," + encoded,
+		base64.RawURLEncoding.EncodeToString([]byte("image?"))} {
+		v, err := DecodeBase64Image(s)
+		assert.Nil(t, err)
+		assert.Equal(t, "image?", string(v))
+	}
+	_, err := DecodeBase64Image("data:text/plain,image")
+	assert.ErrorIs(t, err, ErrInvalidImage)
+	_, err = DecodeBase64Image("data:image/png;base64,not*base64")
+	assert.ErrorIs(t, err, ErrInvalidImage)
+}
+func TestPrepareImageMaxPixels(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 100))))
+	options := DefaultImageOptions
+	options.MaxPixels = 5000
+	_, err := PrepareImage(buf.Bytes(), options)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+	assert.ErrorContains(t, err, "100x100")
+	options.MaxPixels = 10000
+	_, err = PrepareImage(buf.Bytes(), options)
+	assert.Nil(t, err)
+}
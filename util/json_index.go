@@ -0,0 +1,103 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// jsonIndex is the list of items of a store of generated content, kept in a JSON file, e.g. index.json.
+// The file is replaced atomically on every change. Its methods are safe for concurrent use.
+type jsonIndex[T any] struct {
+	mu   sync.Mutex
+	file string
+	id   func(item T) string
+}
+
+func newJSONIndex[T any](file string, id func(item T) string) *jsonIndex[T] {
+	return &jsonIndex[T]{file: file, id: id}
+}
+
+// all returns the items in the order they have been added.
+func (o *jsonIndex[T]) all() ([]T, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.load()
+}
+
+// add appends the item.
+func (o *jsonIndex[T]) add(item T) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	items, err := o.load()
+	if err != nil {
+		return err
+	}
+	return o.save(append(items, item))
+}
+
+// get returns the item with the given id, and false if there is none.
+func (o *jsonIndex[T]) get(id string) (T, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var empty T
+	items, err := o.load()
+	if err != nil {
+		return empty, false, err
+	}
+	i := slices.IndexFunc(items, func(item T) bool { return o.id(item) == id })
+	if i == -1 {
+		return empty, false, nil
+	}
+	return items[i], true, nil
+}
+
+// remove removes the item with the given id and returns it, or false if there is none.
+func (o *jsonIndex[T]) remove(id string) (T, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var empty T
+	items, err := o.load()
+	if err != nil {
+		return empty, false, err
+	}
+	i := slices.IndexFunc(items, func(item T) bool { return o.id(item) == id })
+	if i == -1 {
+		return empty, false, nil
+	}
+	item := items[i]
+	if err := o.save(slices.Delete(items, i, i+1)); err != nil {
+		return empty, false, err
+	}
+	return item, true, nil
+}
+func (o *jsonIndex[T]) load() ([]T, error) {
+	v, err := os.ReadFile(o.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []T
+	if err := json.Unmarshal(v, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+func (o *jsonIndex[T]) save(items []T) error {
+	v, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(o.file), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.file+".tmp", v, 0644); err != nil {
+		return err
+	}
+	return os.Rename(o.file+".tmp", o.file)
+}
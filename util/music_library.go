@@ -0,0 +1,192 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+var ErrTrackNotFound = errors.New("track not found in the music library")
+
+// MusicTrack is a generated song saved in a MusicLibrary. The *File fields are relative to the directory
+// of the track, and empty if the asset is not available.
+type MusicTrack struct {
+	ID            string        `json:"id"`
+	Title         string        `json:"title"`
+	MusicalStyle  string        `json:"musical_style"`
+	Lyrics        string        `json:"lyrics"`
+	Prompt        string        `json:"prompt"`
+	WorkspaceID   int           `json:"workspace_id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	MusicDuration time.Duration `json:"duration"`
+	AudioURL      string        `json:"music_url"`
+	VideoURL      string        `json:"video_url"`
+	CoverImgURL   string        `json:"cover_img_url"`
+	AudioFile     string        `json:"audio_file"`
+	VideoFile     string        `json:"video_file"`
+	CoverImgFile  string        `json:"cover_img_file"`
+}
+
+// MusicQuery filters the tracks of a MusicLibrary. Zero fields match everything.
+type MusicQuery struct {
+	Text        string `json:"text"` // words that must all be in the title, lyrics or prompt
+	Style       string `json:"style"`
+	WorkspaceID int    `json:"workspace_id"`
+}
+
+// MusicLibrary keeps generated songs in a directory, one subdirectory per track containing the assets
+// and a track.json sidecar, plus an index.json of all tracks.
+type MusicLibrary struct {
+	dir   string
+	index *jsonIndex[MusicTrack]
+}
+
+func NewMusicLibrary(dir string) *MusicLibrary {
+	return &MusicLibrary{
+		dir:   dir,
+		index: newJSONIndex(filepath.Join(dir, "index.json"), func(track MusicTrack) string { return track.ID }),
+	}
+}
+
+// Dir returns the directory of the library.
+func (o *MusicLibrary) Dir() string {
+	return o.dir
+}
+
+// TrackDir returns the directory where the assets of the track are saved.
+func (o *MusicLibrary) TrackDir(track MusicTrack) string {
+	return filepath.Join(o.dir, track.ID)
+}
+
+// Save downloads the audio, video and cover of the track, writes its sidecar files and adds it to the index.
+// ID and CreatedAt are filled if empty. The audio is required, while the others are skipped if unavailable.
+func (o *MusicLibrary) Save(ctx context.Context, proxy string, track MusicTrack) (MusicTrack, error) {
+	if track.ID == "" {
+		track.ID = uuid.New().String()
+	}
+	if track.CreatedAt.IsZero() {
+		track.CreatedAt = time.Now()
+	}
+	dir := o.TrackDir(track)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return MusicTrack{}, err
+	}
+	track, err := o.download(ctx, proxy, track)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return MusicTrack{}, err
+	}
+	v, err := json.MarshalIndent(track, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "track.json"), v, 0644)
+	}
+	if err == nil && track.Lyrics != "" {
+		err = os.WriteFile(filepath.Join(dir, "lyrics.txt"), []byte(track.Title+"\n\n"+track.Lyrics), 0644)
+	}
+	if err == nil {
+		err = o.index.add(track)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return MusicTrack{}, err
+	}
+	return track, nil
+}
+func (o *MusicLibrary) download(ctx context.Context, proxy string, track MusicTrack) (MusicTrack, error) {
+	assets := []struct {
+		url      string
+		file     *string
+		name     string
+		required bool
+	}{
+		{track.AudioURL, &track.AudioFile, "audio.mp3", true},
+		{track.VideoURL, &track.VideoFile, "video.mp4", false},
+		{track.CoverImgURL, &track.CoverImgFile, "cover.jpg", false},
+	}
+	for _, asset := range assets {
+		if asset.url == "" {
+			if asset.required {
+				return track, errors.New("the track has no " + strings.TrimSuffix(asset.name, filepath.Ext(asset.name)))
+			}
+			continue
+		}
+		data, err := Download(ctx, proxy, asset.url)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(o.TrackDir(track), asset.name), data, 0644)
+		}
+		if err != nil {
+			if asset.required || ctx.Err() != nil {
+				return track, err
+			}
+			continue
+		}
+		*asset.file = asset.name
+	}
+	return track, nil
+}
+
+// Search returns the tracks matching the query, the newest first.
+func (o *MusicLibrary) Search(query MusicQuery) ([]MusicTrack, error) {
+	tracks, err := o.index.all()
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(query.Text))
+	result := lo.Filter(tracks, func(track MusicTrack, _ int) bool {
+		if query.Style != "" && !strings.EqualFold(track.MusicalStyle, query.Style) {
+			return false
+		}
+		if query.WorkspaceID != 0 && track.WorkspaceID != query.WorkspaceID {
+			return false
+		}
+		text := strings.ToLower(track.Title + "\n" + track.Lyrics + "\n" + track.Prompt)
+		return lo.EveryBy(words, func(word string) bool { return strings.Contains(text, word) })
+	})
+	slices.SortStableFunc(result, func(a, b MusicTrack) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return result, nil
+}
+
+// Styles returns the distinct musical styles of all tracks, sorted.
+func (o *MusicLibrary) Styles() ([]string, error) {
+	tracks, err := o.index.all()
+	if err != nil {
+		return nil, err
+	}
+	styles := lo.Uniq(lo.FilterMap(tracks, func(track MusicTrack, _ int) (string, bool) {
+		return track.MusicalStyle, track.MusicalStyle != ""
+	}))
+	slices.Sort(styles)
+	return styles, nil
+}
+
+// Get returns the track with the given id.
+func (o *MusicLibrary) Get(id string) (MusicTrack, error) {
+	track, ok, err := o.index.get(id)
+	if err == nil && !ok {
+		err = fmt.Errorf("%w: %s", ErrTrackNotFound, id)
+	}
+	return track, err
+}
+
+// Delete removes the track from the index and deletes its directory.
+func (o *MusicLibrary) Delete(id string) error {
+	track, ok, err := o.index.remove(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTrackNotFound, id)
+	}
+	return os.RemoveAll(o.TrackDir(track))
+}
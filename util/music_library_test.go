@@ -0,0 +1,71 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMusicLibrary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("data of " + r.URL.Path))
+	}))
+	defer server.Close()
+	library := NewMusicLibrary(t.TempDir())
+	ctx := context.Background()
+
+	rock, err := library.Save(ctx, "", MusicTrack{Title: "Highway", MusicalStyle: "Rock", Lyrics: "Driving all night",
+		WorkspaceID: 1, AudioURL: server.URL + "/audio", VideoURL: server.URL + "/missing",
+		CoverImgURL: server.URL + "/cover"})
+	assert.Nil(t, err)
+	assert.Equal(t, "audio.mp3", rock.AudioFile)
+	assert.Empty(t, rock.VideoFile)
+	assert.Equal(t, "cover.jpg", rock.CoverImgFile)
+	v, err := os.ReadFile(filepath.Join(library.TrackDir(rock), rock.AudioFile))
+	assert.Nil(t, err)
+	assert.Equal(t, "data of /audio", string(v))
+	assert.FileExists(t, filepath.Join(library.TrackDir(rock), "track.json"))
+	assert.FileExists(t, filepath.Join(library.TrackDir(rock), "lyrics.txt"))
+
+	jazz, err := library.Save(ctx, "", MusicTrack{Title: "Blue Night", MusicalStyle: "jazz", WorkspaceID: 2,
+		AudioURL: server.URL + "/audio"})
+	assert.Nil(t, err)
+	_, err = library.Save(ctx, "", MusicTrack{Title: "No audio", AudioURL: server.URL + "/missing"})
+	assert.NotNil(t, err)
+
+	tracks, err := library.Search(MusicQuery{})
+	assert.Nil(t, err)
+	if assert.Len(t, tracks, 2) {
+		assert.Equal(t, jazz.ID, tracks[0].ID)
+	}
+	tracks, err = library.Search(MusicQuery{Text: "night", Style: "ROCK"})
+	assert.Nil(t, err)
+	if assert.Len(t, tracks, 1) {
+		assert.Equal(t, rock.ID, tracks[0].ID)
+	}
+	tracks, err = library.Search(MusicQuery{WorkspaceID: 2})
+	assert.Nil(t, err)
+	if assert.Len(t, tracks, 1) {
+		assert.Equal(t, jazz.ID, tracks[0].ID)
+	}
+	styles, err := library.Styles()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Rock", "jazz"}, styles)
+
+	assert.Nil(t, library.Delete(rock.ID))
+	assert.NoDirExists(t, library.TrackDir(rock))
+	_, err = library.Get(rock.ID)
+	assert.ErrorIs(t, err, ErrTrackNotFound)
+	entries, err := os.ReadDir(library.Dir())
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2) // index.json and the jazz track
+}
@@ -0,0 +1,24 @@
+package util
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+var tk *tiktoken.Tiktoken
+var initTkFunc = sync.OnceFunc(func() {
+	slog.Info("Init tiktoken")
+	t, err := tiktoken.EncodingForModel("gpt-4")
+	if err != nil {
+		GracefulPanic(err)
+	}
+	tk = t
+})
+
+// CountToken counts the tokens of text with the encoding of GPT-4.
+func CountToken(text string) int {
+	initTkFunc()
+	return len(tk.Encode(text, nil, nil))
+}
@@ -1,24 +1,12 @@
 package util
 
 import (
-	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"github.com/imroc/req/v3"
-	"image"
-	_ "image/gif"
-	"image/jpeg"
-	_ "image/jpeg"
-	_ "image/png"
-	"io"
-	"log"
 	"log/slog"
 	"math/rand"
-	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -30,7 +18,6 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/ncruces/zenity"
-	getproxy "github.com/rapid7/go-get-proxied/proxy"
 )
 
 func RandIntInclusive(min int, max int) int {
@@ -43,45 +30,6 @@ func Ternary[T any](expression bool, trueResult T, falseResult T) T {
 		return falseResult
 	}
 }
-func MakeHTTPClient(proxy string, timeout time.Duration) (*http.Client, *req.Client, error) {
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	reqClient := req.C().ImpersonateChrome().SetCommonHeader("accept-language", "en-US,en;q=0.9")
-	if proxy != "" { // user filled proxy
-		proxyURL, err := url.Parse(proxy)
-		if err != nil {
-			return nil, nil, err
-		}
-		transport.Proxy = http.ProxyURL(proxyURL)
-		reqClient.SetProxyURL(proxy)
-	} else { // try to get system proxy
-		log.SetOutput(io.Discard) // FIXME this is a dirty fix that may result in concurrency problems
-		proxies := []getproxy.Proxy{
-			getproxy.NewProvider("").GetHTTPProxy("https://www.bing.com"),
-			getproxy.NewProvider("").GetHTTPSProxy("https://www.bing.com"),
-			getproxy.NewProvider("").GetSOCKSProxy("https://www.bing.com"),
-		}
-		log.SetOutput(os.Stdout)
-		var sysProxy getproxy.Proxy
-		for _, p := range proxies {
-			p := p
-			if p != nil {
-				sysProxy = p
-				break
-			}
-		}
-		if sysProxy != nil { // valid system proxy
-			transport.Proxy = http.ProxyURL(sysProxy.URL())
-			reqClient.SetProxyURL(sysProxy.URL().String())
-		}
-	}
-	client := &http.Client{}
-	client.Transport = transport
-	if timeout != time.Duration(0) {
-		client.Timeout = timeout
-		reqClient.SetTimeout(timeout)
-	}
-	return client, reqClient, nil
-}
 func FormatCookieString(cookies map[string]string) string {
 	str := ""
 	for k, v := range cookies {
@@ -124,52 +72,6 @@ func MustGenerateRandomHex(length int) string {
 	return randomString
 }
 
-type FileCookie struct {
-	Name  string `json:"name"`
-	Value string `json:"value"`
-}
-
-func ReadCookiesFileRaw() ([]FileCookie, error) {
-	v, err := os.ReadFile(WithPath("cookies.json"))
-	if err != nil {
-		return nil, nil
-	}
-	var cookies []FileCookie
-	err = json.Unmarshal(v, &cookies)
-	if err != nil {
-		return nil, errors.New("failed to json.Unmarshal content of cookie file")
-	}
-	return cookies, nil
-}
-func ReadCookiesFile() (map[string]string, error) {
-	res := map[string]string{}
-	cookies, err := ReadCookiesFileRaw()
-	if err != nil {
-		return nil, err
-	}
-	for _, cookie := range cookies {
-		res[cookie.Name] = cookie.Value
-	}
-	return res, nil
-}
-func UpdateCookiesFile(cookies map[string]string) error {
-	var arr []FileCookie
-	for k, v := range cookies {
-		arr = append(arr, FileCookie{
-			Name:  k,
-			Value: v,
-		})
-	}
-	v, err := json.MarshalIndent(&arr, "", "  ")
-	if err != nil {
-		return err
-	}
-	err = os.WriteFile(WithPath("cookies.json"), v, 0644)
-	if err != nil {
-		return err
-	}
-	return nil
-}
 func Map[T any, E any](arr []T, function func(value T) E) []E {
 	var result []E
 	for _, item := range arr {
@@ -186,21 +88,10 @@ func FindFirst[T any](arr []T, function func(value T) bool) (T, bool) {
 	}
 	return empty, false
 }
+
+// ConvertImageToJpg prepares the image for Bing with DefaultImageOptions.
 func ConvertImageToJpg(img []byte) ([]byte, error) {
-	// Decode the image from the []byte
-	src, _, err := image.Decode(bytes.NewReader(img))
-	if err != nil {
-		return nil, err
-	}
-	// Create a buffer to store the converted image
-	var buf bytes.Buffer
-	// Encode the image as jpg with quality 80
-	err = jpeg.Encode(&buf, src, &jpeg.Options{Quality: 80})
-	if err != nil {
-		return nil, err
-	}
-	// Return the buffer as a []byte
-	return buf.Bytes(), nil
+	return PrepareImage(img, DefaultImageOptions)
 }
 func GenerateSecMSGec() string {
 	// Create a new local random generator
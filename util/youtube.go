@@ -21,11 +21,11 @@ type Youtube struct {
 var ErrNoYtCaptions = errors.New("cannot find youtube captions")
 
 func NewYoutube(url string, proxy string) (*Youtube, error) {
-	_, client, err := MakeHTTPClient(proxy, 15*time.Second)
+	_, sharedClient, err := MakeHTTPClient(proxy, 15*time.Second)
 	if err != nil {
 		return nil, err
 	}
-	client.SetRedirectPolicy(req.NoRedirectPolicy())
+	client := sharedClient.Clone().SetRedirectPolicy(req.NoRedirectPolicy())
 	if strings.HasPrefix(url, "https://youtu.be") {
 		resp, err := client.R().Head(url)
 		if err != nil {
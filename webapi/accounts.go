@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sydneyqt/sydney"
+	"sydneyqt/util"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const (
+	AccountStrategyRoundRobin = "round_robin"
+	AccountStrategyLRU        = "lru"
+)
+
+const (
+	AccountStateHealthy     = "healthy"
+	AccountStateCoolingDown = "cooling_down"
+	AccountStateExpired     = "expired"
+)
+
+// Account is a set of cookies of a Bing account in the pool.
+type Account struct {
+	Name    string
+	Cookies map[string]string
+
+	state         string
+	user          string
+	cooldownUntil time.Time
+	lastPicked    time.Time
+	lastChecked   time.Time
+	lastError     string
+	requests      int
+	failures      int
+}
+
+// AccountStatus is the state of an account reported by the admin endpoint, without its cookies.
+type AccountStatus struct {
+	Name          string     `json:"name"`
+	State         string     `json:"state"`
+	User          string     `json:"user,omitempty"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
+	LastPicked    *time.Time `json:"last_picked,omitempty"`
+	LastChecked   *time.Time `json:"last_checked,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	Requests      int        `json:"requests"`
+	Failures      int        `json:"failures"`
+}
+
+// AccountPool picks an account for each request, skipping those cooling down after being throttled
+// or asked for a CAPTCHA, and those whose cookies have expired.
+type AccountPool struct {
+	mu       sync.Mutex
+	accounts []*Account
+	strategy string
+	cooldown time.Duration
+	next     int // for round-robin
+	getUser  func(ctx context.Context, cookies map[string]string) (string, error)
+}
+
+func NewAccountPool(accounts []*Account, strategy string, cooldown time.Duration, proxy string) (*AccountPool, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("no account in the pool")
+	}
+	strategy = lo.Ternary(strategy == "", AccountStrategyRoundRobin, strategy)
+	if !lo.Contains([]string{AccountStrategyRoundRobin, AccountStrategyLRU}, strategy) {
+		return nil, errors.New("unknown account strategy: " + strategy)
+	}
+	for _, account := range accounts {
+		account.state = AccountStateHealthy
+	}
+	return &AccountPool{
+		accounts: accounts,
+		strategy: strategy,
+		cooldown: cooldown,
+		getUser: func(ctx context.Context, cookies map[string]string) (string, error) {
+			return sydney.NewSydney(sydney.Options{Cookies: cookies, Proxy: proxy}).GetUserContext(ctx)
+		},
+	}, nil
+}
+
+type accountFileItem struct {
+	Name    string `json:"name"`
+	Cookies string `json:"cookies"`
+}
+
+// LoadAccounts reads the accounts from either a directory of cookie files in the format of cookies.json,
+// named after the files, or a JSON file listing them like [{"name": "a", "cookies": "_U=xxx; SRCHHPGUSR=xxx"}].
+func LoadAccounts(path string) ([]*Account, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		files, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		var accounts []*Account
+		for _, file := range files {
+			v, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			var cookies []util.FileCookie
+			if err = json.Unmarshal(v, &cookies); err != nil {
+				return nil, fmt.Errorf("cannot parse cookie file %s: %w", file, err)
+			}
+			accounts = append(accounts, &Account{
+				Name: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+				Cookies: lo.SliceToMap(cookies, func(cookie util.FileCookie) (string, string) {
+					return cookie.Name, cookie.Value
+				}),
+			})
+		}
+		return accounts, nil
+	}
+	v, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var items []accountFileItem
+	if err = json.Unmarshal(v, &items); err != nil {
+		return nil, fmt.Errorf("cannot parse account file %s: %w", path, err)
+	}
+	return lo.Map(items, func(item accountFileItem, i int) *Account {
+		return &Account{
+			Name:    lo.Ternary(item.Name == "", fmt.Sprintf("account-%d", i+1), item.Name),
+			Cookies: ParseCookies(item.Cookies),
+		}
+	}), nil
+}
+
+// Pick returns the next available account. If none is available, the one available the soonest is returned.
+// The cookies returned are a copy, for sydney may update them.
+func (o *AccountPool) Pick() (*Account, map[string]string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	available := lo.Filter(o.accounts, func(account *Account, _ int) bool {
+		return account.available(now)
+	})
+	var account *Account
+	switch {
+	case len(available) == 0:
+		account = lo.MinBy(o.accounts, func(a *Account, b *Account) bool {
+			return a.state != AccountStateExpired &&
+				(b.state == AccountStateExpired || a.cooldownUntil.Before(b.cooldownUntil))
+		})
+		slog.Warn("No account available in the pool", "fallback", account.Name)
+	case o.strategy == AccountStrategyLRU:
+		account = lo.MinBy(available, func(a *Account, b *Account) bool {
+			return a.lastPicked.Before(b.lastPicked)
+		})
+	default:
+		// keep the order of all accounts, so that cooling down ones do not shift the others
+		for i := 0; i < len(o.accounts); i++ {
+			candidate := o.accounts[(o.next+i)%len(o.accounts)]
+			if candidate.available(now) {
+				account = candidate
+				o.next = (o.next + i + 1) % len(o.accounts)
+				break
+			}
+		}
+	}
+	account.lastPicked = now
+	account.requests++
+	return account, util.CopyMap(account.Cookies)
+}
+
+// Use returns the cookies of account for a request bound to it, like a follow-up turn of a session.
+func (o *AccountPool) Use(account *Account) map[string]string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	account.lastPicked = time.Now()
+	account.requests++
+	return util.CopyMap(account.Cookies)
+}
+
+// CookiesUpdater returns the Options.OnCookiesUpdated callback that keeps the cookies updated by Bing or
+// the CAPTCHA solver for the next requests of account, or nil if account is nil.
+func (o *AccountPool) CookiesUpdater(account *Account) func(cookies map[string]string) {
+	if account == nil {
+		return nil
+	}
+	return func(cookies map[string]string) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		account.Cookies = cookies
+	}
+}
+
+// Report updates the state of account by the result of a request. Errors not caused by the account are ignored.
+// account may be nil for requests with their own cookies.
+func (o *AccountPool) Report(account *Account, err error) {
+	if account == nil || err == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	switch {
+	case errors.Is(err, sydney.ErrThrottled) || errors.Is(err, sydney.ErrCaptchaRequired):
+		account.state = AccountStateCoolingDown
+		account.cooldownUntil = time.Now().Add(o.cooldown)
+	case errors.Is(err, sydney.ErrCookieExpired):
+		account.state = AccountStateExpired
+	default:
+		return
+	}
+	account.failures++
+	account.lastError = err.Error()
+	slog.Warn("Account disabled", "account", account.Name, "state", account.state, "err", err)
+}
+
+// Watch reports the error of the stream of account, if any, while passing the messages through until ctx is done.
+// The stream is still drained after that, so that the goroutines sending to it can finish and close the connection.
+func (o *AccountPool) Watch(ctx context.Context, account *Account, ch <-chan sydney.Message) <-chan sydney.Message {
+	out := make(chan sydney.Message)
+	go func() {
+		defer close(out)
+		for msg := range ch {
+			if msg.Type == sydney.MessageTypeError {
+				o.Report(account, msg.Error)
+			}
+			if ctx.Err() != nil {
+				continue
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
+
+// Check checks every account with Sydney.GetUser until ctx is done.
+// Expired accounts recover if it succeeds again.
+func (o *AccountPool) Check(ctx context.Context) {
+	o.mu.Lock()
+	accounts := lo.Map(o.accounts, func(account *Account, _ int) lo.Tuple2[*Account, map[string]string] {
+		return lo.T2(account, util.CopyMap(account.Cookies))
+	})
+	o.mu.Unlock()
+	for _, item := range accounts {
+		user, err := o.getUser(ctx, item.B)
+		if ctx.Err() != nil {
+			return
+		}
+		o.mu.Lock()
+		account := item.A
+		account.lastChecked = time.Now()
+		if err != nil {
+			account.lastError = err.Error()
+			if errors.Is(err, sydney.ErrCookieExpired) {
+				account.state = AccountStateExpired
+			}
+			slog.Warn("Account check failed", "account", account.Name, "err", err)
+		} else {
+			account.user = user
+			if account.state == AccountStateExpired {
+				account.state = AccountStateHealthy
+			}
+		}
+		o.mu.Unlock()
+	}
+}
+
+// CheckEvery runs Check every interval until ctx is done.
+func (o *AccountPool) CheckEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		o.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the state of every account.
+func (o *AccountPool) Status() []AccountStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	optionalTime := func(t time.Time) *time.Time {
+		return lo.Ternary(t.IsZero(), nil, &t)
+	}
+	return lo.Map(o.accounts, func(account *Account, _ int) AccountStatus {
+		return AccountStatus{
+			Name:          account.Name,
+			State:         lo.Ternary(account.available(now), AccountStateHealthy, account.state),
+			User:          account.user,
+			CooldownUntil: lo.Ternary(account.available(now), nil, optionalTime(account.cooldownUntil)),
+			LastPicked:    optionalTime(account.lastPicked),
+			LastChecked:   optionalTime(account.lastChecked),
+			LastError:     account.lastError,
+			Requests:      account.requests,
+			Failures:      account.failures,
+		}
+	})
+}
+
+func (o *Account) available(now time.Time) bool {
+	switch o.state {
+	case AccountStateExpired:
+		return false
+	case AccountStateCoolingDown:
+		return now.After(o.cooldownUntil)
+	}
+	return true
+}
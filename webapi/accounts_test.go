@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sydneyqt/sydney"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAccounts(names ...string) []*Account {
+	var accounts []*Account
+	for _, name := range names {
+		accounts = append(accounts, &Account{Name: name, Cookies: map[string]string{"_U": name}})
+	}
+	return accounts
+}
+func pickNames(pool *AccountPool, n int) []string {
+	var names []string
+	for i := 0; i < n; i++ {
+		account, cookies := pool.Pick()
+		names = append(names, account.Name)
+		cookies["_U"] = "modified" // must not affect the account
+	}
+	return names
+}
+
+func TestLoadAccounts(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "alice.json"),
+		[]byte(`[{"name": "_U", "value": "a"}, {"name": "SRCHHPGUSR", "value": "b"}]`), 0644))
+	accounts, err := LoadAccounts(dir)
+	assert.Nil(t, err)
+	if assert.Len(t, accounts, 1) {
+		assert.Equal(t, "alice", accounts[0].Name)
+		assert.Equal(t, map[string]string{"_U": "a", "SRCHHPGUSR": "b"}, accounts[0].Cookies)
+	}
+	file := filepath.Join(t.TempDir(), "accounts.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`[{"name": "bob", "cookies": "_U=c"}, {"cookies": "_U=d"}]`), 0644))
+	accounts, err = LoadAccounts(file)
+	assert.Nil(t, err)
+	if assert.Len(t, accounts, 2) {
+		assert.Equal(t, "bob", accounts[0].Name)
+		assert.Equal(t, "account-2", accounts[1].Name)
+		assert.Equal(t, map[string]string{"_U": "d"}, accounts[1].Cookies)
+	}
+}
+func TestAccountPoolRoundRobin(t *testing.T) {
+	pool, err := NewAccountPool(newTestAccounts("a", "b", "c"), "", time.Hour, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "a"}, pickNames(pool, 4))
+	pool.Report(pool.accounts[2], sydney.ErrThrottled)
+	pool.Report(pool.accounts[0], errors.New("not the fault of the account"))
+	assert.Equal(t, []string{"b", "a", "b"}, pickNames(pool, 3))
+	assert.Equal(t, "a", pool.accounts[0].Cookies["_U"])
+}
+func TestAccountPoolLRU(t *testing.T) {
+	pool, err := NewAccountPool(newTestAccounts("a", "b", "c"), AccountStrategyLRU, time.Hour, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, pickNames(pool, 3))
+	pool.accounts[1].lastPicked = time.Time{}
+	assert.Equal(t, []string{"b", "a"}, pickNames(pool, 2))
+}
+func TestAccountPoolUnavailable(t *testing.T) {
+	pool, err := NewAccountPool(newTestAccounts("a", "b"), "", time.Hour, "")
+	assert.Nil(t, err)
+	pool.Report(pool.accounts[0], sydney.ErrCookieExpired)
+	pool.Report(pool.accounts[1], sydney.ErrCaptchaRequired)
+	// the cooling down account is still better than the expired one
+	assert.Equal(t, []string{"b"}, pickNames(pool, 1))
+	status := pool.Status()
+	assert.Equal(t, AccountStateExpired, status[0].State)
+	assert.Equal(t, AccountStateCoolingDown, status[1].State)
+	assert.NotNil(t, status[1].CooldownUntil)
+	assert.Equal(t, 1, status[1].Failures)
+	pool.accounts[1].cooldownUntil = time.Now().Add(-time.Second)
+	assert.Equal(t, AccountStateHealthy, pool.Status()[1].State)
+}
+func TestAccountPoolCheck(t *testing.T) {
+	pool, err := NewAccountPool(newTestAccounts("a", "b"), "", time.Hour, "")
+	assert.Nil(t, err)
+	pool.getUser = func(ctx context.Context, cookies map[string]string) (string, error) {
+		if cookies["_U"] == "b" {
+			return "", sydney.ErrCookieExpired
+		}
+		return "User " + cookies["_U"], nil
+	}
+	pool.Report(pool.accounts[0], sydney.ErrCookieExpired)
+	pool.Check(context.Background())
+	status := pool.Status()
+	assert.Equal(t, AccountStateHealthy, status[0].State)
+	assert.Equal(t, "User a", status[0].User)
+	assert.NotNil(t, status[0].LastChecked)
+	assert.Equal(t, AccountStateExpired, status[1].State)
+}
+func TestNewAccountPoolInvalid(t *testing.T) {
+	_, err := NewAccountPool(nil, "", time.Hour, "")
+	assert.NotNil(t, err)
+	_, err = NewAccountPool(newTestAccounts("a"), "random", time.Hour, "")
+	assert.NotNil(t, err)
+}
+func TestAccountPoolWatch(t *testing.T) {
+	pool, err := NewAccountPool(newTestAccounts("a"), "", time.Hour, "")
+	assert.Nil(t, err)
+	ch := make(chan sydney.Message)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		defer close(ch)
+		ch <- sydney.Message{Type: sydney.MessageTypeMessageText, Text: "hi"}
+		ch <- sydney.Message{Type: sydney.MessageTypeMessageText, Text: "there"}
+		ch <- sydney.Message{Type: sydney.MessageTypeError, Error: sydney.ErrThrottled}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	out := pool.Watch(ctx, pool.accounts[0], ch)
+	assert.Equal(t, "hi", (<-out).Text)
+	cancel()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("the stream is not drained after the context is done")
+	}
+	for range out {
+	}
+	assert.Equal(t, AccountStateCoolingDown, pool.accounts[0].state)
+}
+func TestAccountPoolCookiesUpdater(t *testing.T) {
+	pool, err := NewAccountPool(newTestAccounts("a"), "", time.Hour, "")
+	assert.Nil(t, err)
+	assert.Nil(t, pool.CookiesUpdater(nil))
+	pool.CookiesUpdater(pool.accounts[0])(map[string]string{"_U": "a", "cct": "new"})
+	_, cookies := pool.Pick()
+	assert.Equal(t, "new", cookies["cct"])
+}
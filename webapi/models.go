@@ -1,6 +1,9 @@
 package main
 
-import "sydneyqt/sydney"
+import (
+	"sydneyqt/sydney"
+	"sydneyqt/util"
+)
 
 type CreateConversationRequest struct {
 	Cookies string `json:"cookies"`
@@ -11,6 +14,19 @@ type CreateImageRequest struct {
 	Cookies string                 `json:"cookies"`
 }
 
+type CreateMusicRequest struct {
+	Prompt  string `json:"prompt"`
+	Cookies string `json:"cookies"`
+}
+
+// MusicTrackResponse is a track of the music library with the URLs of its stored assets on this server.
+type MusicTrackResponse struct {
+	util.MusicTrack
+	AudioAssetURL    string `json:"audio_asset_url,omitempty"`
+	VideoAssetURL    string `json:"video_asset_url,omitempty"`
+	CoverImgAssetURL string `json:"cover_img_asset_url,omitempty"`
+}
+
 type ChatStreamRequest struct {
 	Prompt            string   `json:"prompt"`
 	WebpageContext    string   `json:"context"`
@@ -21,6 +37,8 @@ type ChatStreamRequest struct {
 	UseClassic        bool     `json:"classic"`
 	ConversationStyle string   `json:"conversationStyle"`
 	Plugins           []string `json:"plugins"`
+	Session           string   `json:"session"`
+	Location          string   `json:"location"`
 }
 
 // The `content` field can have different types
@@ -66,6 +84,7 @@ type OpenAIChatCompletionRequest struct {
 	Stream       bool                              `json:"stream"`
 	ToolChoice   *interface{}                      `json:"tool_choice"`
 	Conversation sydney.CreateConversationResponse `json:"conversation"`
+	Session      string                            `json:"session"`
 }
 
 type ChoiceDelta struct {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sydneyqt/sydney"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSessionMismatch = errors.New("the session has been created with other cookies or options")
+	ErrSessionBusy     = errors.New("the session is answering another request")
+)
+
+// SessionStore keeps sydney sessions for clients that send a session id,
+// so that follow-up requests reuse the same Bing conversation.
+// A session only holds the conversation; each turn is asked by the Sydney of its own request.
+type SessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*sessionEntry
+}
+
+type sessionEntry struct {
+	session  *sydney.Session
+	key      string   // of the cookies and the options the session has been created with
+	account  *Account // of the pool picked by the first turn, nil if the request has its own cookies
+	busy     bool
+	lastUsed time.Time
+}
+
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	store := &SessionStore{
+		ttl:      ttl,
+		sessions: map[string]*sessionEntry{},
+	}
+	go store.cleaner()
+	return store
+}
+
+// SessionKey returns the key binding a session to the cookies and the options of its requests.
+func SessionKey(cookies string, options ...any) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%q", cookies)
+	for _, option := range options {
+		_, _ = fmt.Fprintf(h, "\x00%v", option)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Acquire returns the session by id for a turn, creating it if it does not exist, along with the account
+// of the pool it is bound to, if any. It fails with ErrSessionMismatch if the session has been created with
+// another key, and with ErrSessionBusy if another turn is running. Release must be called after the turn.
+func (o *SessionStore) Acquire(id string, key string) (*sydney.Session, *Account, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, ok := o.sessions[id]
+	if !ok {
+		entry = &sessionEntry{session: sydney.NewSession(), key: key}
+		o.sessions[id] = entry
+	}
+	if entry.key != key {
+		return nil, nil, ErrSessionMismatch
+	}
+	if entry.busy {
+		return nil, nil, ErrSessionBusy
+	}
+	entry.busy = true
+	entry.lastUsed = time.Now()
+	return entry.session, entry.account, nil
+}
+
+// SetAccount binds the session to the account of the pool picked by its first turn.
+func (o *SessionStore) SetAccount(id string, account *Account) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if entry, ok := o.sessions[id]; ok {
+		entry.account = account
+	}
+}
+
+// Release ends the turn of the session acquired by Acquire.
+func (o *SessionStore) Release(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if entry, ok := o.sessions[id]; ok {
+		entry.busy = false
+		entry.lastUsed = time.Now()
+	}
+}
+
+func (o *SessionStore) cleaner() {
+	for range time.Tick(time.Minute) {
+		o.mu.Lock()
+		for id, entry := range o.sessions {
+			if !entry.busy && time.Since(entry.lastUsed) > o.ttl {
+				delete(o.sessions, id)
+			}
+		}
+		o.mu.Unlock()
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStore(t *testing.T) {
+	store := NewSessionStore(time.Minute)
+	key := SessionKey("_U=alice", "Creative", false)
+	session, account, err := store.Acquire("s1", key)
+	assert.Nil(t, err)
+	assert.Nil(t, account)
+	_, _, err = store.Acquire("s1", key)
+	assert.ErrorIs(t, err, ErrSessionBusy)
+	store.SetAccount("s1", &Account{Name: "a"})
+	store.Release("s1")
+
+	_, _, err = store.Acquire("s1", SessionKey("_U=mallory", "Creative", false))
+	assert.ErrorIs(t, err, ErrSessionMismatch)
+	_, _, err = store.Acquire("s1", SessionKey("_U=alice", "Precise", false))
+	assert.ErrorIs(t, err, ErrSessionMismatch)
+
+	again, account, err := store.Acquire("s1", key)
+	assert.Nil(t, err)
+	assert.Same(t, session, again)
+	if assert.NotNil(t, account) {
+		assert.Equal(t, "a", account.Name)
+	}
+}
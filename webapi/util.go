@@ -1,7 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
 	"strings"
+	"sydneyqt/sydney"
+	"sydneyqt/util"
+	"time"
 )
 
 func ParseCookies(cookiesStr string) map[string]string {
@@ -14,3 +22,75 @@ func ParseCookies(cookiesStr string) map[string]string {
 	}
 	return cookies
 }
+
+// ErrorStatusCode maps the errors of packages sydney and util to an HTTP status code.
+func ErrorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, sydney.ErrCookieExpired):
+		return http.StatusUnauthorized
+	case errors.Is(err, sydney.ErrRegionUnsupported):
+		return http.StatusUnavailableForLegalReasons
+	case errors.Is(err, sydney.ErrThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, sydney.ErrCaptchaRequired):
+		return http.StatusForbidden
+	case errors.Is(err, sydney.ErrNetwork):
+		return http.StatusBadGateway
+	case errors.Is(err, sydney.ErrStreamStalled):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, sydney.ErrContextTooLong):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, sydney.ErrMessageFiltered):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, util.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, util.ErrInvalidImage):
+		return http.StatusBadRequest
+	case errors.Is(err, util.ErrTrackNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// parseDurationEnv parses the environment variable key like 10m, returning defaultValue if it is not set.
+func parseDurationEnv(key string, defaultValue time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+func ToMusicTrackResponse(track util.MusicTrack) MusicTrackResponse {
+	assetURL := func(file string) string {
+		return util.Ternary(file == "", "", "/music/"+track.ID+"/"+file)
+	}
+	return MusicTrackResponse{
+		MusicTrack:       track,
+		AudioAssetURL:    assetURL(track.AudioFile),
+		VideoAssetURL:    assetURL(track.VideoFile),
+		CoverImgAssetURL: assetURL(track.CoverImgFile),
+	}
+}
+
+// uploadDataURLImage uploads the image of a data URL to Bing, returning the URL of the uploaded image.
+// Other URLs are returned as is.
+func uploadDataURLImage(ctx context.Context, sydneyAPI *sydney.Sydney, imageURL string,
+	options util.ImageOptions) (string, error) {
+	if !strings.HasPrefix(imageURL, "data:") {
+		return imageURL, nil
+	}
+	data, err := util.DecodeBase64Image(imageURL)
+	if err != nil {
+		return "", err
+	}
+	data, err = util.PrepareImage(data, options)
+	if err != nil {
+		return "", err
+	}
+	return sydneyAPI.UploadImageContext(ctx, data)
+}
@@ -9,12 +9,17 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sydneyqt/sydney"
 	"sydneyqt/util"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/samber/lo"
 )
 
 func main() {
@@ -36,19 +41,136 @@ func main() {
 
 	noLog := os.Getenv("NO_LOG") != ""
 
-	defaultCookies := ParseCookies(os.Getenv("DEFAULT_COOKIES"))
-	if len(defaultCookies) == 0 {
-		slog.Info("DEFAULT_COOKIES not set, reading from cookies.json")
-		defaultCookies, _ = util.ReadCookiesFile()
-		if len(defaultCookies) == 0 {
-			slog.Warn("cookies.json not found, using empty cookies")
+	var accounts []*Account
+	if accountsPath := os.Getenv("ACCOUNTS"); accountsPath != "" {
+		var err error
+		accounts, err = LoadAccounts(accountsPath)
+		if err != nil {
+			log.Fatal(err)
 		}
+		slog.Info("ACCOUNTS set, DEFAULT_COOKIES and cookies.json will be ignored", "accounts", len(accounts))
 	} else {
-		slog.Info("DEFAULT_COOKIES set, cookies.json will be ignored")
+		defaultCookies := ParseCookies(os.Getenv("DEFAULT_COOKIES"))
+		if len(defaultCookies) == 0 {
+			slog.Info("DEFAULT_COOKIES not set, reading from cookies.json")
+			defaultCookies, _ = util.ReadCookiesFile()
+			if len(defaultCookies) == 0 {
+				slog.Warn("cookies.json not found, using empty cookies")
+			}
+		} else {
+			slog.Info("DEFAULT_COOKIES set, cookies.json will be ignored")
+		}
+		accounts = []*Account{{Name: "default", Cookies: defaultCookies}}
+	}
+	accountCooldown, err := parseDurationEnv("ACCOUNT_COOLDOWN", 10*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	accountCheckInterval, err := parseDurationEnv("ACCOUNT_CHECK_INTERVAL", 30*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	accountPool, err := NewAccountPool(accounts, os.Getenv("ACCOUNT_STRATEGY"), accountCooldown, proxy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if accountCheckInterval > 0 {
+		go accountPool.CheckEvery(context.Background(), accountCheckInterval)
+	}
+	sessions := NewSessionStore(30 * time.Minute)
+	// pickCookies returns the cookies of the request if any, or those of an account of the pool otherwise.
+	pickCookies := func(cookiesStr string) (map[string]string, *Account) {
+		if cookiesStr != "" {
+			return ParseCookies(cookiesStr), nil
+		}
+		account, cookies := accountPool.Pick()
+		return cookies, account
+	}
+	// pickSessionCookies works like pickCookies, but a session keeps the account of the pool picked
+	// by its first turn.
+	pickSessionCookies := func(cookiesStr string, sessionID string, sessionAccount *Account) (map[string]string,
+		*Account) {
+		if cookiesStr == "" && sessionAccount != nil {
+			return accountPool.Use(sessionAccount), sessionAccount
+		}
+		cookies, account := pickCookies(cookiesStr)
+		if sessionID != "" {
+			sessions.SetAccount(sessionID, account)
+		}
+		return cookies, account
 	}
 
 	authToken := os.Getenv("AUTH_TOKEN")
 
+	revokeReplyText := os.Getenv("REVOKE_REPLY_TEXT")
+	if revokeReplyText == "" {
+		revokeReplyText = "Continue from where you stopped."
+	}
+	revokeReplyCount, _ := strconv.Atoi(os.Getenv("REVOKE_REPLY_COUNT"))
+
+	rewriteCitations := os.Getenv("REWRITE_CITATIONS") != ""
+
+	contextTokenBudget, _ := strconv.Atoi(os.Getenv("CONTEXT_TOKEN_BUDGET"))
+	contextTrimStrategy := os.Getenv("CONTEXT_TRIM_STRATEGY")
+	if contextTrimStrategy != "" && !slices.Contains(sydney.TrimStrategies(), contextTrimStrategy) {
+		log.Fatal("unknown CONTEXT_TRIM_STRATEGY: " + contextTrimStrategy)
+	}
+
+	imageOptions := util.DefaultImageOptions
+	if v, _ := strconv.Atoi(os.Getenv("IMAGE_MAX_DIMENSION")); v > 0 {
+		imageOptions.MaxDimension = v
+	}
+	if v, _ := strconv.Atoi(os.Getenv("IMAGE_MAX_SIZE")); v > 0 {
+		imageOptions.MaxBytes = v * 1024
+	}
+
+	uploadCacheTTL, err := parseDurationEnv("UPLOAD_CACHE_TTL", sydney.DefaultUploadCacheTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var uploadCache *sydney.UploadCache
+	if uploadCacheTTL > 0 {
+		uploadCache = sydney.NewUploadCache(util.WithPath("upload_cache.json"), uploadCacheTTL)
+	}
+
+	keepaliveInterval, err := parseDurationEnv("KEEPALIVE_INTERVAL", sydney.DefaultKeepaliveInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	readTimeout, err := parseDurationEnv("READ_TIMEOUT", sydney.DefaultReadTimeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	idleTimeout, err := parseDurationEnv("IDLE_TIMEOUT", sydney.DefaultIdleTimeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pollInterval, err := parseDurationEnv("POLL_INTERVAL", sydney.DefaultPollInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pollAttempts, _ := strconv.Atoi(os.Getenv("POLL_ATTEMPTS"))
+
+	bypassServer := os.Getenv("BYPASS_SERVER")
+	captchaSolver := os.Getenv("CAPTCHA_SOLVER")
+	if captchaSolver != "" && !slices.Contains(sydney.CaptchaSolverNames(), captchaSolver) {
+		log.Fatal("unknown CAPTCHA_SOLVER: " + captchaSolver)
+	}
+
+	if err := sydney.LoadPluginFile(util.WithPath("plugins.json")); err != nil {
+		log.Fatal(err)
+	}
+	if err := sydney.LoadConversationStyleFile(util.WithPath("styles.json")); err != nil {
+		log.Fatal(err)
+	}
+
+	musicLibraryDir := os.Getenv("MUSIC_LIBRARY")
+	if musicLibraryDir == "" {
+		musicLibraryDir = util.WithPath("music")
+	}
+	musicLibrary := util.NewMusicLibrary(musicLibraryDir)
+
 	// create router
 	r := chi.NewRouter()
 
@@ -97,12 +219,39 @@ func main() {
 		fmt.Fprint(w, "OK")
 	})
 
+	r.Get("/plugins", func(w http.ResponseWriter, r *http.Request) {
+		// set headers
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		// write response
+		json.NewEncoder(w).Encode(sydney.Plugins())
+	})
+
+	// the state of the accounts is only served behind the auth token
+	if authToken != "" {
+		r.Get("/admin/accounts", func(w http.ResponseWriter, r *http.Request) {
+			// set headers
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+			// write response
+			json.NewEncoder(w).Encode(accountPool.Status())
+		})
+	}
+
+	r.Get("/styles", func(w http.ResponseWriter, r *http.Request) {
+		// set headers
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		// write response
+		json.NewEncoder(w).Encode(sydney.ConversationStyles())
+	})
+
 	r.Post("/image/upload", func(w http.ResponseWriter, r *http.Request) {
 		// parse request
 		r.ParseMultipartForm(16 << 20)
 
 		cookiesStr := r.FormValue("cookies")
-		cookies := util.Ternary(cookiesStr == "", defaultCookies, ParseCookies(cookiesStr))
+		cookies, account := pickCookies(cookiesStr)
 
 		file, _, err := r.FormFile("file")
 		if err != nil {
@@ -116,14 +265,22 @@ func main() {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		bytes, err = util.PrepareImage(bytes, imageOptions)
+		if err != nil {
+			http.Error(w, "error preparing image: "+err.Error(), ErrorStatusCode(err))
+			return
+		}
 
 		// upload image
 		imgUrl, err := sydney.
 			NewSydney(sydney.Options{
-				Cookies: cookies,
-				Proxy:   proxy,
+				Cookies:          cookies,
+				Proxy:            proxy,
+				UploadCache:      uploadCache,
+				OnCookiesUpdated: accountPool.CookiesUpdater(account),
 			}).
-			UploadImage(bytes)
+			UploadImageContext(r.Context(), bytes)
+		accountPool.Report(account, err)
 
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -146,7 +303,7 @@ func main() {
 			return
 		}
 
-		cookies := util.Ternary(request.Cookies == "", defaultCookies, ParseCookies(request.Cookies))
+		cookies, account := pickCookies(request.Cookies)
 
 		// create image
 		image, err := sydney.
@@ -154,8 +311,12 @@ func main() {
 				Cookies:           cookies,
 				Proxy:             proxy,
 				ConversationStyle: "Creative",
+				OnCookiesUpdated:  accountPool.CookiesUpdater(account),
+				PollInterval:      pollInterval,
+				PollAttempts:      pollAttempts,
 			}).
-			GenerateImage(request.Image)
+			GenerateImageContext(r.Context(), request.Image)
+		accountPool.Report(account, err)
 
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -181,29 +342,73 @@ func main() {
 			return
 		}
 
-		cookies := util.Ternary(request.Cookies == "", defaultCookies, ParseCookies(request.Cookies))
+		var session *sydney.Session
+		var sessionAccount *Account
+		if request.Session != "" {
+			session, sessionAccount, err = sessions.Acquire(request.Session, SessionKey(request.Cookies,
+				request.ConversationStyle, request.Location, request.NoSearch, request.UseGPT4Turbo,
+				request.UseClassic, request.Plugins))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			defer sessions.Release(request.Session)
+		}
+		cookies, account := pickSessionCookies(request.Cookies, request.Session, sessionAccount)
+
+		var location sydney.Location
+		if request.Location != "" {
+			var ok bool
+			location, ok = sydney.FindLocation(request.Location)
+			if !ok {
+				http.Error(w, "unknown location: "+request.Location, http.StatusBadRequest)
+				return
+			}
+		}
 
 		sydneyAPI := sydney.NewSydney(sydney.Options{
 			Cookies:           cookies,
 			Proxy:             proxy,
 			ConversationStyle: request.ConversationStyle,
+			Location:          location,
 			NoSearch:          request.NoSearch,
 			GPT4Turbo:         request.UseGPT4Turbo,
 			UseClassic:        request.UseClassic,
 			Plugins:           request.Plugins,
+			BypassServer:      bypassServer,
+			CaptchaSolverName: captchaSolver,
+			OnCookiesUpdated:  accountPool.CookiesUpdater(account),
+			KeepaliveInterval: keepaliveInterval,
+			ReadTimeout:       readTimeout,
+			IdleTimeout:       idleTimeout,
+			UploadCache:       uploadCache,
 		})
 
+		imageURL, err := uploadDataURLImage(r.Context(), sydneyAPI, request.ImageURL, imageOptions)
+		if err != nil {
+			http.Error(w, "error uploading image: "+err.Error(), ErrorStatusCode(err))
+			return
+		}
+
 		// stream chat
 		messageCh, err := sydneyAPI.AskStream(sydney.AskStreamOptions{
-			StopCtx:        r.Context(),
-			Prompt:         request.Prompt,
-			WebpageContext: request.WebpageContext,
-			ImageURL:       request.ImageURL,
+			StopCtx:          r.Context(),
+			Session:          session,
+			Prompt:           request.Prompt,
+			WebpageContext:   request.WebpageContext,
+			ImageURL:         imageURL,
+			RevokeReplyText:  revokeReplyText,
+			RevokeReplyCount: revokeReplyCount,
+			TokenBudget:      contextTokenBudget,
+			TrimStrategy:     contextTrimStrategy,
+			Summarize:        sydney.SummarizeWith(sydneyAPI),
 		})
+		accountPool.Report(account, err)
 		if err != nil {
-			http.Error(w, "error creating conversation: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "error creating conversation: "+err.Error(), ErrorStatusCode(err))
 			return
 		}
+		messageCh = accountPool.Watch(r.Context(), account, messageCh)
 
 		// set headers
 		w.Header().Set("Content-Type", "text/event-stream; charset=UTF-8")
@@ -211,9 +416,25 @@ func main() {
 		w.Header().Set("Connection", "keep-alive")
 
 		// write response
+		wrote := false
+		var pending []sydney.Message
 		for message := range messageCh {
-			encoded, _ := json.Marshal(message.Text)
-			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, encoded)
+			if message.Type == sydney.MessageTypeError && !wrote {
+				// nothing has been sent yet, so the status code can still tell what went wrong
+				http.Error(w, message.Text, ErrorStatusCode(message.Error))
+				return
+			}
+			if message.Type == sydney.MessageTypeContextTrimmed && !wrote {
+				// held back until the conversation turns out to be fine
+				pending = append(pending, message)
+				continue
+			}
+			wrote = true
+			for _, message := range append(pending, message) {
+				encoded, _ := json.Marshal(message.Text)
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, encoded)
+			}
+			pending = nil
 			if f, ok := w.(http.Flusher); ok {
 				f.Flush()
 			}
@@ -237,10 +458,19 @@ func main() {
 		}
 
 		cookiesStr := r.Header.Get("Cookie")
-		cookies := util.Ternary(cookiesStr == "", defaultCookies, ParseCookies(cookiesStr))
+		conversationStyle := sydney.ConversationStyleForModel(request.Model)
 
-		conversationStyle := util.Ternary(
-			strings.HasPrefix(request.Model, "gpt-3.5-turbo"), "Balanced", "Creative")
+		var session *sydney.Session
+		var sessionAccount *Account
+		if request.Session != "" {
+			session, sessionAccount, err = sessions.Acquire(request.Session, SessionKey(cookiesStr, conversationStyle))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			defer sessions.Release(request.Session)
+		}
+		cookies, account := pickSessionCookies(cookiesStr, request.Session, sessionAccount)
 
 		sydneyAPI := sydney.NewSydney(sydney.Options{
 			Cookies:           cookies,
@@ -249,18 +479,40 @@ func main() {
 			Locale:            "id-ID",
 			NoSearch:          false,
 			GPT4Turbo:         true,
+			BypassServer:      bypassServer,
+			CaptchaSolverName: captchaSolver,
+			OnCookiesUpdated:  accountPool.CookiesUpdater(account),
+			KeepaliveInterval: keepaliveInterval,
+			ReadTimeout:       readTimeout,
+			IdleTimeout:       idleTimeout,
+			UploadCache:       uploadCache,
 		})
 
+		imageURL, err := uploadDataURLImage(r.Context(), sydneyAPI, parsedMessages.ImageURL, imageOptions)
+		if err != nil {
+			http.Error(w, "error uploading image: "+err.Error(), ErrorStatusCode(err))
+			return
+		}
+
 		messageCh, err := sydneyAPI.AskStream(sydney.AskStreamOptions{
-			StopCtx:        r.Context(),
-			Prompt:         parsedMessages.Prompt,
-			WebpageContext: parsedMessages.WebpageContext,
-			ImageURL:       parsedMessages.ImageURL,
+			StopCtx:          r.Context(),
+			Session:          session,
+			Prompt:           parsedMessages.Prompt,
+			WebpageContext:   parsedMessages.WebpageContext,
+			ImageURL:         imageURL,
+			RevokeReplyText:  revokeReplyText,
+			RevokeReplyCount: revokeReplyCount,
+			RewriteCitations: rewriteCitations,
+			TokenBudget:      contextTokenBudget,
+			TrimStrategy:     contextTrimStrategy,
+			Summarize:        sydney.SummarizeWith(sydneyAPI),
 		})
+		accountPool.Report(account, err)
 		if err != nil {
-			http.Error(w, "error creating conversation: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "error creating conversation: "+err.Error(), ErrorStatusCode(err))
 			return
 		}
+		messageCh = accountPool.Watch(r.Context(), account, messageCh)
 
 		// handle non-stream
 		if !request.Stream {
@@ -276,6 +528,10 @@ func main() {
 				case sydney.MessageTypeMessageText:
 					replyBuilder.WriteString(message.Text)
 				case sydney.MessageTypeError:
+					if replyBuilder.Len() == 0 {
+						http.Error(w, message.Text, ErrorStatusCode(message.Error))
+						return
+					}
 					errored = true
 					replyBuilder.WriteString("`Error: ")
 					replyBuilder.WriteString(message.Text)
@@ -299,6 +555,7 @@ func main() {
 
 		// write response
 		errored := false
+		wrote := false
 
 		for message := range messageCh {
 			var delta string
@@ -307,6 +564,10 @@ func main() {
 			case sydney.MessageTypeMessageText:
 				delta = message.Text
 			case sydney.MessageTypeError:
+				if !wrote {
+					http.Error(w, message.Text, ErrorStatusCode(message.Error))
+					return
+				}
 				errored = true
 				delta = fmt.Sprintf("`Error: %s`", message.Text)
 			default:
@@ -323,6 +584,7 @@ func main() {
 			if f, ok := w.(http.Flusher); ok {
 				f.Flush()
 			}
+			wrote = true
 		}
 
 		// write final chunk
@@ -342,13 +604,21 @@ func main() {
 		}
 
 		cookiesStr := r.Header.Get("Cookie")
-		cookies := util.Ternary(cookiesStr == "", defaultCookies, ParseCookies(cookiesStr))
+		cookies, account := pickCookies(cookiesStr)
 
 		sydneyAPI := sydney.NewSydney(sydney.Options{
 			Cookies:           cookies,
 			Proxy:             proxy,
 			ConversationStyle: "Creative",
 			Locale:            "en-US",
+			BypassServer:      bypassServer,
+			CaptchaSolverName: captchaSolver,
+			OnCookiesUpdated:  accountPool.CookiesUpdater(account),
+			KeepaliveInterval: keepaliveInterval,
+			ReadTimeout:       readTimeout,
+			IdleTimeout:       idleTimeout,
+			PollInterval:      pollInterval,
+			PollAttempts:      pollAttempts,
 		})
 
 		// ask stream
@@ -360,19 +630,23 @@ func main() {
 			Prompt:         "Create image for the description: " + request.Prompt,
 			WebpageContext: ImageGeneratorContext,
 		})
+		accountPool.Report(account, err)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), ErrorStatusCode(err))
 			return
 		}
+		messageCh = accountPool.Watch(newContext, account, messageCh)
 
 		var generativeImage sydney.GenerativeImage
 
 		for message := range messageCh {
+			if message.Type == sydney.MessageTypeError {
+				http.Error(w, message.Text, ErrorStatusCode(message.Error))
+				return
+			}
 			if message.Type == sydney.MessageTypeGenerativeImage {
-				err := json.Unmarshal([]byte(message.Text), &generativeImage)
-				if err == nil {
-					break
-				}
+				generativeImage = *message.GenerativeImage
+				break
 			}
 		}
 		cancel()
@@ -383,9 +657,10 @@ func main() {
 		}
 
 		// create image
-		image, err := sydneyAPI.GenerateImage(generativeImage)
+		image, err := sydneyAPI.GenerateImageContext(r.Context(), generativeImage)
+		accountPool.Report(account, err)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), ErrorStatusCode(err))
 			return
 		}
 
@@ -396,6 +671,138 @@ func main() {
 		json.NewEncoder(w).Encode(ToOpenAIImageGeneration(image))
 	})
 
+	r.Post("/music/create", func(w http.ResponseWriter, r *http.Request) {
+		var request CreateMusicRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Prompt == "" {
+			http.Error(w, "empty prompt", http.StatusBadRequest)
+			return
+		}
+
+		cookies, account := pickCookies(request.Cookies)
+
+		sydneyAPI := sydney.NewSydney(sydney.Options{
+			Cookies:           cookies,
+			Proxy:             proxy,
+			ConversationStyle: "Creative",
+			Locale:            "en-US",
+			Plugins:           []string{"Suno"},
+			BypassServer:      bypassServer,
+			CaptchaSolverName: captchaSolver,
+			OnCookiesUpdated:  accountPool.CookiesUpdater(account),
+			KeepaliveInterval: keepaliveInterval,
+			ReadTimeout:       readTimeout,
+			IdleTimeout:       idleTimeout,
+			PollInterval:      pollInterval,
+			PollAttempts:      pollAttempts,
+		})
+
+		// ask stream
+		newContext, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		messageCh, err := sydneyAPI.AskStream(sydney.AskStreamOptions{
+			StopCtx:        newContext,
+			Prompt:         "Create a song for the description: " + request.Prompt,
+			WebpageContext: MusicGeneratorContext,
+		})
+		accountPool.Report(account, err)
+		if err != nil {
+			http.Error(w, err.Error(), ErrorStatusCode(err))
+			return
+		}
+		messageCh = accountPool.Watch(newContext, account, messageCh)
+
+		var generativeMusic sydney.GenerativeMusic
+
+		for message := range messageCh {
+			if message.Type == sydney.MessageTypeError {
+				http.Error(w, message.Text, ErrorStatusCode(message.Error))
+				return
+			}
+			if message.Type == sydney.MessageTypeGenerativeMusic {
+				generativeMusic = *message.GenerativeMusic
+				break
+			}
+		}
+		cancel()
+
+		if generativeMusic.RequestID == "" {
+			http.Error(w, "empty generative music", http.StatusInternalServerError)
+			return
+		}
+
+		// create music
+		music, err := sydneyAPI.GenerateMusicContext(r.Context(), generativeMusic)
+		accountPool.Report(account, err)
+		if err != nil {
+			http.Error(w, err.Error(), ErrorStatusCode(err))
+			return
+		}
+
+		// store assets
+		track, err := musicLibrary.Save(r.Context(), proxy, util.MusicTrack{
+			Title:         music.Title,
+			MusicalStyle:  music.MusicalStyle,
+			Lyrics:        music.Lyrics,
+			Prompt:        request.Prompt,
+			MusicDuration: music.MusicDuration,
+			AudioURL:      music.AudioURL,
+			VideoURL:      music.VideoURL,
+			CoverImgURL:   music.CoverImgURL,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// set headers
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		// write response
+		json.NewEncoder(w).Encode(ToMusicTrackResponse(track))
+	})
+
+	r.Get("/music", func(w http.ResponseWriter, r *http.Request) {
+		workspaceID, _ := strconv.Atoi(r.URL.Query().Get("workspace_id"))
+		tracks, err := musicLibrary.Search(util.MusicQuery{
+			Text:        r.URL.Query().Get("text"),
+			Style:       r.URL.Query().Get("style"),
+			WorkspaceID: workspaceID,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// set headers
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		// write response
+		json.NewEncoder(w).Encode(lo.Map(tracks, func(track util.MusicTrack, _ int) MusicTrackResponse {
+			return ToMusicTrackResponse(track)
+		}))
+	})
+
+	r.Get("/music/{id}/{file}", func(w http.ResponseWriter, r *http.Request) {
+		track, err := musicLibrary.Get(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), ErrorStatusCode(err))
+			return
+		}
+		file := chi.URLParam(r, "file")
+		if !slices.Contains([]string{track.AudioFile, track.VideoFile, track.CoverImgFile}, file) || file == "" {
+			http.Error(w, "asset not found: "+file, http.StatusNotFound)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(musicLibrary.TrackDir(track), file))
+	})
+
 	// serve the router
 	log.Println("Listening on :" + port)
 	log.Fatal(http.ListenAndServe(":"+port, r))